@@ -0,0 +1,157 @@
+package sseserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ConnectionInfo 是一个活跃连接的快照
+type ConnectionInfo struct {
+	ID           string    `json:"id"`
+	RemoteIP     string    `json:"remote_ip"`
+	Namespaces   []string  `json:"namespaces"`
+	UserID       string    `json:"user_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+	BufferLen    int       `json:"buffer_len"`
+	BufferCap    int       `json:"buffer_cap"`
+}
+
+type queueStats struct {
+	Len int `json:"len"`
+	Cap int `json:"cap"`
+}
+
+// HubStats 是 hub 队列与计数器的快照
+type HubStats struct {
+	ActiveConnections int32      `json:"active_connections"`
+	DroppedMessages   int64      `json:"dropped_messages"`
+	BroadcastWorkers  int        `json:"broadcast_workers"`
+	Shards            int        `json:"shards"`
+	BroadcastChannel  queueStats `json:"broadcast_channel"`
+	BroadcastQueue    queueStats `json:"broadcast_queue"`
+	UnregisterQueue   queueStats `json:"unregister_queue"`
+}
+
+func (c *connection) info() ConnectionInfo {
+	c.mu.Lock()
+	lastActivity := c.lastActivity
+	c.mu.Unlock()
+	return ConnectionInfo{
+		ID:           c.id,
+		RemoteIP:     c.remoteIP,
+		Namespaces:   append([]string(nil), c.namespaces...),
+		UserID:       c.userID,
+		CreatedAt:    c.createdAt,
+		LastActivity: lastActivity,
+		BufferLen:    len(c.send),
+		BufferCap:    cap(c.send),
+	}
+}
+
+// Connections 返回所有活跃连接的快照，按创建时间排序
+func (s *Server) Connections() []ConnectionInfo {
+	h := s.hub
+	infos := make([]ConnectionInfo, 0, h.GetActiveConnectionCount())
+	h.eachShardConnection(func(conn *connection) {
+		infos = append(infos, conn.info())
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// Disconnect 强制断开指定 ID 的连接，返回连接是否存在
+func (s *Server) Disconnect(id string) bool {
+	h := s.hub
+	conn := h.connectionByID(id)
+	if conn == nil {
+		return false
+	}
+	h.dropConnections([]*connection{conn}, DisconnectKicked)
+	return true
+}
+
+// DisconnectIP 强制断开来自指定 IP 的全部连接，返回断开的连接数
+func (s *Server) DisconnectIP(ip string) int {
+	h := s.hub
+	var conns []*connection
+	h.eachShardConnection(func(conn *connection) {
+		if conn.remoteIP == ip {
+			conns = append(conns, conn)
+		}
+	})
+	h.dropConnections(conns, DisconnectKicked)
+	return len(conns)
+}
+
+// Stats 返回 hub 的队列与计数器快照
+func (s *Server) Stats() HubStats {
+	h := s.hub
+	return HubStats{
+		ActiveConnections: h.GetActiveConnectionCount(),
+		DroppedMessages:   h.GetDroppedMessageCount(),
+		BroadcastWorkers:  len(h.broadcastQueues),
+		Shards:            len(h.shards),
+		BroadcastChannel:  queueStats{Len: len(h.broadcast), Cap: cap(h.broadcast)},
+		BroadcastQueue:    h.broadcastQueueStats(),
+		UnregisterQueue:   queueStats{Len: len(h.unregister), Cap: cap(h.unregister)},
+	}
+}
+
+// addAdminEndpoints 注册 /admin/ 管理接口，每个请求都需通过 AdminAuthorize：
+//
+//	GET  /admin/connections             列出活跃连接
+//	POST /admin/disconnect?id=<连接 ID>  断开单个连接
+//	POST /admin/disconnect?ip=<IP>      断开某个 IP 的全部连接
+//	GET  /admin/stats                   hub 队列统计
+func (s *Server) addAdminEndpoints() {
+	s.mux.Handle("/admin/connections", s.adminAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.Connections())
+	}))
+	s.mux.Handle("/admin/disconnect", s.adminAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var n int
+		if id := r.URL.Query().Get("id"); id != "" {
+			if s.Disconnect(id) {
+				n = 1
+			}
+		} else if ip := r.URL.Query().Get("ip"); ip != "" {
+			n = s.DisconnectIP(ip)
+		} else {
+			http.Error(w, "id or ip is required", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"disconnected": n})
+	}))
+	s.mux.Handle("/admin/stats", s.adminAuth(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Stats())
+	}))
+}
+
+// adminAuth 以 AdminAuthorize 校验管理接口的访问权限
+func (s *Server) adminAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Options.AdminAuthorize(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
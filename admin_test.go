@@ -0,0 +1,125 @@
+package sseserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminEndpoints(t *testing.T) {
+	server := NewServer(ServerOptions{
+		AdminAuthorize: func(r *http.Request) bool { return true },
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/subscribe/sysenv", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	waitUntil(t, time.Second, func() bool {
+		return server.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	lresp, err := http.Get(ts.URL + "/admin/connections")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns []ConnectionInfo
+	json.NewDecoder(lresp.Body).Decode(&conns)
+	lresp.Body.Close()
+	if len(conns) != 1 {
+		t.Fatalf("连接列表长度错误，得到 %d", len(conns))
+	}
+	if len(conns[0].Namespaces) != 1 || conns[0].Namespaces[0] != "/sysenv" || conns[0].RemoteIP != "127.0.0.1" || conns[0].BufferCap != 256 {
+		t.Errorf("连接信息错误: %+v", conns[0])
+	}
+	if conns[0].ID != resp.Header.Get("X-SSE-Connection-ID") {
+		t.Errorf("连接 ID 与响应头不一致")
+	}
+
+	sresp, err := http.Get(ts.URL + "/admin/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats HubStats
+	json.NewDecoder(sresp.Body).Decode(&stats)
+	sresp.Body.Close()
+	if stats.ActiveConnections != 1 || stats.BroadcastQueue.Cap != 2048 {
+		t.Errorf("统计信息错误: %+v", stats)
+	}
+
+	dresp, err := http.Post(ts.URL+"/admin/disconnect?ip=127.0.0.1", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]int
+	json.NewDecoder(dresp.Body).Decode(&result)
+	dresp.Body.Close()
+	if result["disconnected"] != 1 {
+		t.Errorf("断开连接数错误: %v", result)
+	}
+	waitUntil(t, time.Second, func() bool {
+		return server.GetActiveConnectionCount() == 0
+	}, "连接未被断开")
+}
+
+func TestAdminEndpointsAuth(t *testing.T) {
+	server := NewServer(ServerOptions{
+		AdminAuthorize: func(r *http.Request) bool {
+			return r.Header.Get("X-Admin-Token") == "secret"
+		},
+	})
+	defer server.Stop()
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/stats", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("未授权访问状态码错误，得到 %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/stats", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("授权访问状态码错误，得到 %d", rr.Code)
+	}
+
+	disabled := NewServer(ServerOptions{
+		AdminAuthorize:        func(r *http.Request) bool { return true },
+		DisableAdminEndpoints: true,
+	})
+	defer disabled.Stop()
+	rr = httptest.NewRecorder()
+	disabled.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/stats", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("禁用后管理接口应返回 404，得到 %d", rr.Code)
+	}
+}
+
+// 未配置 AdminAuthorize 时不注册管理接口，本机请求同样无法访问
+func TestAdminEndpointsOptIn(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	for _, path := range []string{"/admin/stats", "/admin/connections", "/admin/disconnect?ip=127.0.0.1"} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = "127.0.0.1:5000"
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, r)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("未配置 AdminAuthorize 时 %s 应返回 404，得到 %d", path, rr.Code)
+		}
+	}
+}
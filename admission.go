@@ -0,0 +1,251 @@
+package sseserver
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultAdmissionRetryAfter = 5 * time.Second
+
+// 拒绝连接的原因，同时作为 sse_connections_rejected_total 的 reason 标签
+const (
+	rejectMaxConnections = iota
+	rejectIP
+	rejectPrincipal
+	rejectNamespace
+	rejectRate
+	rejectReasons
+)
+
+var rejectReasonNames = [rejectReasons]string{"max_connections", "ip", "principal", "namespace", "rate"}
+
+// admission 在发送响应头之前检查新连接能否接入，并为通过的连接占用名额直到连接结束。
+// 按 IP、身份、namespace 的计数只在配置了对应上限时维护。
+type admission struct {
+	mu          sync.Mutex
+	total       int
+	byIP        map[string]int
+	byPrincipal map[string]int
+	byNamespace map[string]int
+	bucket      *tokenBucket
+	rejected    [rejectReasons]uint64
+}
+
+func newAdmission(opts ServerOptions) *admission {
+	a := &admission{}
+	if opts.MaxConnectionsPerIP > 0 {
+		a.byIP = make(map[string]int)
+	}
+	if opts.MaxConnectionsPerPrincipal > 0 {
+		a.byPrincipal = make(map[string]int)
+	}
+	if opts.MaxConnectionsPerNamespace > 0 {
+		a.byNamespace = make(map[string]int)
+	}
+	if opts.ConnectionRate > 0 {
+		a.bucket = newTokenBucket(opts.ConnectionRate, opts.ConnectionBurst)
+	}
+	return a
+}
+
+// rejection 描述被拒绝的连接应收到的响应
+type rejection struct {
+	reason     int
+	status     int
+	retryAfter time.Duration
+}
+
+// admissionSlot 是通过准入的连接占用的名额，连接结束时释放。
+// namespaces 是准入时的副本，由 admission 的锁保护，动态订阅变化时通过 update 同步。
+type admissionSlot struct {
+	a          *admission
+	limit      int // MaxConnectionsPerNamespace
+	retryAfter time.Duration
+	ip         string
+	identity   string
+	namespaces []string
+	released   bool
+}
+
+// admit 检查全局、单 IP、单身份与单 namespace 的连接上限以及新连接速率，
+// 通过时返回占用的名额。identity 为空的匿名连接不受单身份上限约束。
+// 速率最后检查，因连接数上限被拒绝的请求不消耗令牌。
+func (s *Server) admit(ip, identity string, namespaces []string) (*admissionSlot, *rejection) {
+	a := s.admission
+	opts := &s.Options
+	retryAfter := opts.AdmissionRetryAfter
+	if retryAfter <= 0 {
+		retryAfter = defaultAdmissionRetryAfter
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if max := s.maxConnections(); max > 0 && a.total >= max {
+		return nil, a.reject(rejectMaxConnections, http.StatusServiceUnavailable, retryAfter)
+	}
+	if a.byIP != nil && a.byIP[ip] >= opts.MaxConnectionsPerIP {
+		return nil, a.reject(rejectIP, http.StatusTooManyRequests, retryAfter)
+	}
+	if a.byPrincipal != nil && identity != "" && a.byPrincipal[identity] >= opts.MaxConnectionsPerPrincipal {
+		return nil, a.reject(rejectPrincipal, http.StatusTooManyRequests, retryAfter)
+	}
+	if a.byNamespace != nil {
+		for _, ns := range namespaces {
+			if a.byNamespace[ns] >= opts.MaxConnectionsPerNamespace {
+				return nil, a.reject(rejectNamespace, http.StatusServiceUnavailable, retryAfter)
+			}
+		}
+	}
+	if a.bucket != nil {
+		if wait, ok := a.bucket.take(time.Now()); !ok {
+			return nil, a.reject(rejectRate, http.StatusServiceUnavailable, wait)
+		}
+	}
+
+	a.total++
+	if a.byIP != nil {
+		a.byIP[ip]++
+	}
+	if a.byPrincipal != nil && identity != "" {
+		a.byPrincipal[identity]++
+	}
+	if a.byNamespace != nil {
+		for _, ns := range namespaces {
+			a.byNamespace[ns]++
+		}
+	}
+	return &admissionSlot{
+		a:          a,
+		limit:      opts.MaxConnectionsPerNamespace,
+		retryAfter: retryAfter,
+		ip:         ip,
+		identity:   identity,
+		namespaces: append([]string(nil), namespaces...),
+	}, nil
+}
+
+// release 释放名额，重复调用无效
+func (slot *admissionSlot) release() {
+	a := slot.a
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if slot.released {
+		return
+	}
+	slot.released = true
+	a.total--
+	if a.byIP != nil {
+		decrement(a.byIP, slot.ip)
+	}
+	if a.byPrincipal != nil && slot.identity != "" {
+		decrement(a.byPrincipal, slot.identity)
+	}
+	if a.byNamespace != nil {
+		for _, ns := range slot.namespaces {
+			decrement(a.byNamespace, ns)
+		}
+	}
+}
+
+// update 在连接动态增删订阅时检查单 namespace 上限并更新计数，
+// 新增的 namespace 中任意一个已满时整体拒绝，计数保持不变
+func (slot *admissionSlot) update(add, remove []string) *rejection {
+	a := slot.a
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if slot.released {
+		return nil
+	}
+	if a.byNamespace != nil {
+		for _, ns := range add {
+			if a.byNamespace[ns] >= slot.limit {
+				return a.reject(rejectNamespace, http.StatusServiceUnavailable, slot.retryAfter)
+			}
+		}
+		for _, ns := range remove {
+			decrement(a.byNamespace, ns)
+		}
+		for _, ns := range add {
+			a.byNamespace[ns]++
+		}
+	}
+	next := slot.namespaces[:0:0]
+	for _, ns := range slot.namespaces {
+		if !containsNamespace(remove, ns) {
+			next = append(next, ns)
+		}
+	}
+	slot.namespaces = append(next, add...)
+	return nil
+}
+
+func (a *admission) reject(reason, status int, retryAfter time.Duration) *rejection {
+	atomic.AddUint64(&a.rejected[reason], 1)
+	return &rejection{reason: reason, status: status, retryAfter: retryAfter}
+}
+
+func decrement(counts map[string]int, key string) {
+	counts[key]--
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// write 以 Retry-After（整秒，向上取整）响应被拒绝的连接
+func (r *rejection) write(w http.ResponseWriter) {
+	secs := int(math.Ceil(r.retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	msg := "Too many connections"
+	if r.reason == rejectRate {
+		msg = "Too many new connections"
+	}
+	http.Error(w, msg, r.status)
+}
+
+// maxConnections 返回全局连接上限，0 表示不限制
+func (s *Server) maxConnections() int {
+	switch max := s.Options.MaxConnections; {
+	case max < 0:
+		return 0
+	case max == 0:
+		return MaxConnections
+	default:
+		return max
+	}
+}
+
+// tokenBucket 限制新连接的速率：令牌以 rate 个/秒补充，最多积累 burst 个
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	b := float64(burst)
+	if burst <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b}
+}
+
+// take 取走一个令牌；令牌不足时返回补足一个令牌需要等待的时间。调用方负责加锁
+func (b *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+}
@@ -0,0 +1,189 @@
+package sseserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdmissionControl(t *testing.T) {
+	server := NewServer(ServerOptions{
+		MaxConnections:             3,
+		MaxConnectionsPerPrincipal: 1,
+		MaxConnectionsPerNamespace: 2,
+		AdmissionRetryAfter:        2 * time.Second,
+		UserIDFunc: func(r *http.Request) string {
+			return r.Header.Get("X-User")
+		},
+	})
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	open := func(path, user string) (*http.Response, context.CancelFunc) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+path, nil)
+		req.Header.Set("X-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+		return resp, cancel
+	}
+	expectRejected := func(path, user string, status int, msg string) {
+		t.Helper()
+		resp, cancel := open(path, user)
+		defer cancel()
+		defer resp.Body.Close()
+		if resp.StatusCode != status || resp.Header.Get("Retry-After") != "2" {
+			t.Errorf("%s: 状态码 %d，Retry-After %q", msg, resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+	}
+
+	_, cancelA := open("/subscribe/room", "alice")
+	defer cancelA()
+	expectRejected("/subscribe/lobby", "alice", http.StatusTooManyRequests, "超过单身份上限应返回 429")
+
+	_, cancelB := open("/subscribe/room", "bob")
+	defer cancelB()
+	expectRejected("/subscribe/room", "carol", http.StatusServiceUnavailable, "超过单 namespace 上限应返回 503")
+
+	_, cancelC := open("/subscribe/lobby", "")
+	expectRejected("/subscribe/hall", "", http.StatusServiceUnavailable, "超过全局上限应返回 503")
+
+	// 连接断开后释放名额
+	cancelC()
+	waitUntil(t, time.Second, func() bool {
+		server.admission.mu.Lock()
+		defer server.admission.mu.Unlock()
+		return server.admission.total == 2
+	}, "断开的连接未释放名额")
+	resp, cancelD := open("/subscribe/hall", "")
+	defer cancelD()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("释放名额后应允许接入，得到 %d", resp.StatusCode)
+	}
+
+	rr := httptest.NewRecorder()
+	server.writeMetrics(rr)
+	body, _ := io.ReadAll(rr.Body)
+	for _, want := range []string{
+		`sse_connections_rejected_total{reason="max_connections"} 1`,
+		`sse_connections_rejected_total{reason="principal"} 1`,
+		`sse_connections_rejected_total{reason="namespace"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("指标中缺少 %s", want)
+		}
+	}
+}
+
+// 动态订阅同样受单 namespace 上限约束，取消订阅后名额立即释放，连接断开后计数归零
+func TestAdmissionLiveSubscriptions(t *testing.T) {
+	server := NewServer(ServerOptions{
+		MaxConnectionsPerNamespace: 1,
+		AdmissionRetryAfter:        2 * time.Second,
+		EnableSubscriptionAPI:      true,
+	})
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	open := func(path string) (*http.Response, context.CancelFunc) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+		return resp, cancel
+	}
+	counts := func() map[string]int {
+		server.admission.mu.Lock()
+		defer server.admission.mu.Unlock()
+		out := make(map[string]int)
+		for ns, n := range server.admission.byNamespace {
+			out[ns] = n
+		}
+		return out
+	}
+
+	respA, cancelA := open("/subscribe/a")
+	idA := respA.Header.Get("X-SSE-Connection-ID")
+	respB, cancelB := open("/subscribe/b")
+	defer cancelB()
+	idB := respB.Header.Get("X-SSE-Connection-ID")
+
+	resp, err := http.Post(ts.URL+"/subscriptions/"+idB, "application/json", strings.NewReader(`{"subscribe":["/a"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "2" {
+		t.Errorf("动态订阅已满的 namespace 应返回 503，得到 %d，Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if err := server.Subscribe(idB, "/a"); err != ErrNamespaceLimit {
+		t.Errorf("Server.Subscribe 订阅已满的 namespace 应返回 ErrNamespaceLimit，得到 %v", err)
+	}
+
+	if err := server.Unsubscribe(idA, "/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Subscribe(idB, "/a"); err != nil {
+		t.Errorf("取消订阅后名额应释放，得到 %v", err)
+	}
+	if got := counts(); got["/a"] != 1 || got["/b"] != 1 {
+		t.Errorf("动态订阅后计数错误: %v", got)
+	}
+
+	cancelA()
+	cancelB()
+	waitUntil(t, time.Second, func() bool { return len(counts()) == 0 }, "连接断开后 namespace 计数未归零")
+	resp, cancel := open("/subscribe/a")
+	defer cancel()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("计数归零后应允许接入，得到 %d", resp.StatusCode)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, ok := bucket.take(now); !ok {
+			t.Fatalf("突发容量内的第 %d 个连接被拒绝", i+1)
+		}
+	}
+	wait, ok := bucket.take(now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("令牌耗尽时应拒绝并等待 500ms，得到 %v %v", ok, wait)
+	}
+	if _, ok := bucket.take(now.Add(500 * time.Millisecond)); !ok {
+		t.Errorf("补充令牌后应允许接入")
+	}
+	if _, ok := bucket.take(now.Add(10 * time.Second)); !ok || bucket.tokens != 2 {
+		t.Errorf("令牌数不应超过突发容量，剩余 %v", bucket.tokens)
+	}
+
+	server := NewServer(ServerOptions{ConnectionRate: 0.5})
+	defer server.Stop()
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/subscribe/", nil).WithContext(canceledContext()))
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/subscribe/", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "2" {
+		t.Errorf("超过新连接速率应返回 503 与 Retry-After，得到 %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
@@ -0,0 +1,202 @@
+package sseserver
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const dedupWindow = 4096
+
+// Broker 在多个 Server 实例之间分发消息。配置 Broker 后，Server.Broadcast 收到的消息
+// 先发布到 Broker，再由每个节点的订阅回调投递给本节点的连接，
+// 使负载均衡后面任意节点的广播都能到达全部客户端。
+type Broker interface {
+	// Publish 将消息发布给所有订阅者（包括发布者自身所在的节点）
+	Publish(msg SSEMessage) error
+	// Subscribe 注册消息回调，返回的 cancel 用于取消订阅
+	Subscribe(handler func(SSEMessage)) (cancel func(), err error)
+}
+
+// ContextPublisher 是 Broker 可选实现的接口，Server.Publish 通过它把 ctx 传给发布操作。
+// 未实现时 ctx 结束后 Server.Publish 立即返回，但发布操作仍会在后台完成。
+type ContextPublisher interface {
+	PublishContext(ctx context.Context, msg SSEMessage) error
+}
+
+// MemoryBroker 进程内 Broker，可在同一进程的多个 Server 之间共享
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[int]func(SSEMessage)
+	nextID   int
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: make(map[int]func(SSEMessage))}
+}
+
+func (b *MemoryBroker) Publish(msg SSEMessage) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(handler func(SSEMessage)) (func(), error) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}, nil
+}
+
+// dedup 记录最近见过的消息 ID，用于丢弃经由多条路径重复到达的消息
+type dedup struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+	ring []string
+	next int
+}
+
+func newDedup(size int) *dedup {
+	return &dedup{
+		seen: make(map[string]struct{}, size),
+		ring: make([]string, size),
+	}
+}
+
+// duplicate 返回 id 是否已经出现过，未出现时记录下来
+func (d *dedup) duplicate(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.seen[id]; ok {
+		return true
+	}
+	if old := d.ring[d.next]; old != "" {
+		delete(d.seen, old)
+	}
+	d.ring[d.next] = id
+	d.next = (d.next + 1) % len(d.ring)
+	d.seen[id] = struct{}{}
+	return false
+}
+
+// startBroker 接管 Broadcast 通道：发往该通道的消息先分配全局唯一 ID 再发布到 Broker，
+// Broker 投递回来的消息经去重后进入 hub。
+func (s *Server) startBroker() {
+	outbound := make(chan SSEMessage, 1024)
+	s.Broadcast = outbound
+	s.dedup = newDedup(dedupWindow)
+
+	cancel, err := s.Options.Broker.Subscribe(s.receiveFromBroker)
+	if err != nil {
+		s.logger.Error("broker subscribe failed", "err", err)
+	} else {
+		go func() {
+			<-s.stopChan
+			cancel()
+		}()
+	}
+
+	// 服务停止时中断进行中的发布
+	ctx, stopPublish := context.WithCancel(context.Background())
+	go func() {
+		defer stopPublish()
+		for {
+			select {
+			case msg := <-outbound:
+				s.publishToBroker(ctx, msg)
+			case <-s.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Server) publishToBroker(ctx context.Context, msg SSEMessage) error {
+	if msg.ID == "" {
+		msg.ID = s.nodeID + "-" + strconv.FormatUint(atomic.AddUint64(&s.hub.nextID, 1), 10)
+	}
+	err := brokerPublish(ctx, s.Options.Broker, msg)
+	if err != nil {
+		atomic.AddInt64(&s.hub.droppedMessages, 1)
+		s.logger.Error("broker publish failed", "id", msg.ID, "namespace", msg.Namespace, "err", err)
+	}
+	return err
+}
+
+// brokerPublish 发布消息并在 ctx 结束时返回，Broker 未实现 ContextPublisher 时发布在后台继续
+func brokerPublish(ctx context.Context, b Broker, msg SSEMessage) error {
+	if cp, ok := b.(ContextPublisher); ok {
+		return cp.PublishContext(ctx, msg)
+	}
+	if ctx.Done() == nil {
+		return b.Publish(msg)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Publish(msg)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) receiveFromBroker(msg SSEMessage) {
+	if msg.ID != "" && s.dedup.duplicate(msg.ID) {
+		return
+	}
+	select {
+	case s.hub.broadcast <- msg:
+	case <-s.stopChan:
+	}
+}
+
+// brokerMessage 是消息在网络 Broker 中传输的 JSON 格式
+type brokerMessage struct {
+	ID          string `json:"id,omitempty"`
+	Event       string `json:"event,omitempty"`
+	Data        []byte `json:"data"`
+	Namespace   string `json:"namespace,omitempty"`
+	Retry       int64  `json:"retry,omitempty"` // 纳秒
+	OrderingKey string `json:"ordering_key,omitempty"`
+}
+
+func encodeBrokerMessage(msg SSEMessage) ([]byte, error) {
+	return json.Marshal(brokerMessage{
+		ID:          msg.ID,
+		Event:       msg.Event,
+		Data:        msg.Data,
+		Namespace:   msg.Namespace,
+		Retry:       int64(msg.Retry),
+		OrderingKey: msg.OrderingKey,
+	})
+}
+
+func decodeBrokerMessage(payload []byte) (SSEMessage, error) {
+	var bm brokerMessage
+	if err := json.Unmarshal(payload, &bm); err != nil {
+		return SSEMessage{}, err
+	}
+	return SSEMessage{
+		ID:          bm.ID,
+		Event:       bm.Event,
+		Data:        bm.Data,
+		Namespace:   bm.Namespace,
+		Retry:       time.Duration(bm.Retry),
+		OrderingKey: bm.OrderingKey,
+	}, nil
+}
@@ -0,0 +1,316 @@
+package sseserver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis 是只支持 PUBLISH/SUBSCRIBE/PING 的本地 Redis 替身，muted 时不回复 PING，模拟半开的连接
+type fakeRedis struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	subs  map[string][]net.Conn
+	muted bool
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, subs: make(map[string][]net.Conn)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) subscribers(channel string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs[channel])
+}
+
+func (f *fakeRedis) setMuted(muted bool) {
+	f.mu.Lock()
+	f.muted = muted
+	f.mu.Unlock()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		args, _ := reply.([]any)
+		if len(args) == 0 {
+			return
+		}
+		cmd, _ := args[0].([]byte)
+		if strings.ToUpper(string(cmd)) == "PING" {
+			f.mu.Lock()
+			if !f.muted {
+				writeRESPCommand(conn, "pong", nil)
+			}
+			f.mu.Unlock()
+			continue
+		}
+		if len(args) < 2 {
+			return
+		}
+		channel, _ := args[1].([]byte)
+		switch strings.ToUpper(string(cmd)) {
+		case "SUBSCRIBE":
+			f.mu.Lock()
+			f.subs[string(channel)] = append(f.subs[string(channel)], conn)
+			f.mu.Unlock()
+			writeRESPCommand(conn, "subscribe", channel, []byte("1"))
+		case "PUBLISH":
+			payload, _ := args[2].([]byte)
+			f.mu.Lock()
+			subs := f.subs[string(channel)]
+			for _, sub := range subs {
+				writeRESPCommand(sub, "message", channel, payload)
+			}
+			f.mu.Unlock()
+			conn.Write([]byte(":" + strconv.Itoa(len(subs)) + "\r\n"))
+		}
+	}
+}
+
+func subscribeAndRead(t *testing.T, url string, lines int) <-chan string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		reader := bufio.NewReader(resp.Body)
+		var got []string
+		for i := 0; i < lines; i++ {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break
+			}
+			got = append(got, line)
+		}
+		out <- strings.Join(got, "")
+	}()
+	return out
+}
+
+func TestMemoryBrokerFanOut(t *testing.T) {
+	broker := NewMemoryBroker()
+	nodeA := NewServer(ServerOptions{Broker: broker, NodeID: "a"})
+	nodeB := NewServer(ServerOptions{Broker: broker, NodeID: "b"})
+	tsB := httptest.NewServer(nodeB)
+	defer tsB.Close()
+	defer nodeA.Stop()
+	defer nodeB.Stop()
+
+	got := subscribeAndRead(t, tsB.URL+"/subscribe/", 4)
+	waitUntil(t, time.Second, func() bool {
+		return nodeB.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	nodeA.Broadcast <- SSEMessage{Event: "cluster", Data: []byte("from a")}
+
+	select {
+	case resp := <-got:
+		expected := "id:a-1\nevent:cluster\ndata:from a\n\n"
+		if resp != expected {
+			t.Errorf("跨节点广播错误。得到: %q, 想要: %q", resp, expected)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("节点 B 未收到节点 A 的广播")
+	}
+}
+
+func TestBrokerDeduplicate(t *testing.T) {
+	broker := NewMemoryBroker()
+	server := NewServer(ServerOptions{Broker: broker})
+	defer server.Stop()
+
+	conn := server.hub.newConnection()
+	server.hub.registerConnection(conn)
+	waitUntil(t, time.Second, func() bool {
+		return server.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	msg := SSEMessage{ID: "dup-1", Data: []byte("once")}
+	broker.Publish(msg)
+	broker.Publish(msg)
+	time.Sleep(100 * time.Millisecond)
+
+	if n := len(conn.send); n != 1 {
+		t.Errorf("重复 ID 的消息应只投递一次，实际 %d 次", n)
+	}
+}
+
+func TestRedisBrokerFanOut(t *testing.T) {
+	redis := newFakeRedis(t)
+	addr := redis.ln.Addr().String()
+
+	brokerA := NewRedisBroker(addr, "sse")
+	brokerB := NewRedisBroker(addr, "sse")
+	defer brokerA.Close()
+	defer brokerB.Close()
+
+	nodeA := NewServer(ServerOptions{Broker: brokerA, NodeID: "a"})
+	nodeB := NewServer(ServerOptions{Broker: brokerB, NodeID: "b"})
+	tsB := httptest.NewServer(nodeB)
+	defer tsB.Close()
+	defer nodeA.Stop()
+	defer nodeB.Stop()
+
+	waitUntil(t, 2*time.Second, func() bool {
+		return redis.subscribers("sse") == 2
+	}, "节点未订阅 Redis 频道")
+	got := subscribeAndRead(t, tsB.URL+"/subscribe/sysenv", 6)
+	waitUntil(t, time.Second, func() bool {
+		return nodeB.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	nodeA.Broadcast <- SSEMessage{Event: "env", Data: []byte("line1\nline2"), Namespace: "/sysenv"}
+
+	select {
+	case resp := <-got:
+		expected := "id:a-1\nevent:env\nnamespace:/sysenv\ndata:line1\ndata:line2\n\n"
+		if resp != expected {
+			t.Errorf("经 Redis 的跨节点广播错误。得到: %q, 想要: %q", resp, expected)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("节点 B 未收到经 Redis 转发的广播")
+	}
+}
+
+// 订阅连接不再响应时按 PING 超时判定断开，重连后恢复订阅，并记录断开与恢复
+func TestRedisBrokerResubscribe(t *testing.T) {
+	redis := newFakeRedis(t)
+	logger := &recordLogger{}
+	broker := NewRedisBroker(redis.ln.Addr().String(), "sse")
+	broker.PingInterval = 20 * time.Millisecond
+	broker.Timeout = 20 * time.Millisecond
+	broker.ReconnectDelay = 10 * time.Millisecond
+	broker.Logger = logger
+	defer broker.Close()
+
+	received := make(chan SSEMessage, 1)
+	if _, err := broker.Subscribe(func(msg SSEMessage) { received <- msg }); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, time.Second, func() bool { return redis.subscribers("sse") == 1 }, "未订阅 Redis 频道")
+
+	redis.setMuted(true)
+	waitUntil(t, time.Second, func() bool {
+		return logger.find(`level=WARN msg="redis subscription lost, reconnecting"`) != ""
+	}, "订阅连接无响应时未判定断开")
+	redis.setMuted(false)
+	waitUntil(t, time.Second, func() bool {
+		return logger.find(`level=INFO msg="redis subscription restored"`) != ""
+	}, "重连后未记录恢复")
+
+	if err := broker.Publish(SSEMessage{ID: "1", Data: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if msg.ID != "1" {
+			t.Errorf("重连后收到的消息错误: %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("重连后未收到消息")
+	}
+}
+
+// Redis 不回复时发布在 Timeout 后失败，ctx 取消时立即返回
+func TestRedisBrokerPublishTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	broker := NewRedisBroker(ln.Addr().String(), "sse")
+	broker.Timeout = 50 * time.Millisecond
+	defer broker.Close()
+	start := time.Now()
+	if err := broker.Publish(SSEMessage{Data: []byte("x")}); err == nil {
+		t.Error("Redis 不回复时发布应失败")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("发布超时未生效，耗时 %v", elapsed)
+	}
+
+	broker.Timeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	if err := broker.PublishContext(ctx, SSEMessage{Data: []byte("x")}); err != context.Canceled {
+		t.Errorf("ctx 取消后应返回 context.Canceled，得到 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ctx 取消后未及时返回，耗时 %v", elapsed)
+	}
+}
+
+// blockingBroker 的 Publish 阻塞到 release 关闭
+type blockingBroker struct {
+	release chan struct{}
+}
+
+func (b *blockingBroker) Publish(msg SSEMessage) error {
+	<-b.release
+	return nil
+}
+
+func (b *blockingBroker) Subscribe(handler func(SSEMessage)) (func(), error) {
+	return func() {}, nil
+}
+
+func TestServerPublishBrokerContext(t *testing.T) {
+	broker := &blockingBroker{release: make(chan struct{})}
+	defer close(broker.release)
+	server := NewServer(ServerOptions{Broker: broker})
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := server.Publish(ctx, SSEMessage{Data: []byte("x")}); err != context.DeadlineExceeded {
+		t.Errorf("Broker 阻塞时 Publish 应在 ctx 超时后返回，得到 %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+// Package client 实现订阅 sseserver 事件流的 Go 客户端，支持断线自动重连、
+// Last-Event-ID 续传以及服务端下发的 retry 重连间隔。
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInitialBackoff = 3 * time.Second
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// ErrUnexpectedContentType 表示服务端返回的不是 text/event-stream
+var ErrUnexpectedContentType = errors.New("sse client: unexpected content type")
+
+// StatusError 表示服务端以非 200 状态码拒绝了订阅
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // 响应中 Retry-After 指定的等待时间（仅支持秒数形式），0 = 未指定
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sse client: unexpected status %d", e.StatusCode)
+}
+
+// temporary 判断该状态码是否值得重试：5xx、408 与 429 重试，其余 4xx 视为永久错误
+func (e *StatusError) temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+type Client struct {
+	URL        string
+	HTTPClient *http.Client // 为 nil 时使用不带超时的默认 Client
+	Header     http.Header  // 每次请求附带的额外请求头，如 Authorization
+
+	InitialBackoff time.Duration // 首次重连等待时间，服务端下发 retry 后以其为准，0 = 默认 3s
+	MaxBackoff     time.Duration // 连续失败时指数退避的上限，0 = 默认 30s
+
+	OnConnect func()          // 每次成功建立连接后调用
+	OnError   func(err error) // 连接失败或中断时调用，之后会自动重连
+
+	mu          sync.Mutex
+	lastEventID string
+	retry       time.Duration
+}
+
+func New(url string) *Client {
+	return &Client{URL: url}
+}
+
+// LastEventID 返回最近收到的事件 ID，重连时通过 Last-Event-ID 请求头发送
+func (c *Client) LastEventID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastEventID
+}
+
+// SetLastEventID 设置续传起点，通常用于进程重启后从持久化的位置继续订阅
+func (c *Client) SetLastEventID(id string) {
+	c.mu.Lock()
+	c.lastEventID = id
+	c.mu.Unlock()
+}
+
+// Subscribe 订阅事件流并对每条事件调用 handler，连接中断后按退避策略自动重连。
+// 阻塞直到 ctx 结束、服务端返回 204（要求停止重连）或遇到不可重试的错误。
+func (c *Client) Subscribe(ctx context.Context, handler func(Event)) error {
+	failures := 0
+	for {
+		connected, err := c.connect(ctx, handler)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			if statusErr.StatusCode == http.StatusNoContent {
+				return nil
+			}
+			if !statusErr.temporary() {
+				return err
+			}
+		}
+		if errors.Is(err, ErrUnexpectedContentType) {
+			return err
+		}
+		if err != nil && c.OnError != nil {
+			c.OnError(err)
+		}
+
+		if connected {
+			failures = 0
+		} else {
+			failures++
+		}
+		delay := c.backoff(failures)
+		// 服务端因连接数或速率限制拒绝时按 Retry-After 等待，避免在名额释放前反复重试
+		if statusErr != nil && statusErr.RetryAfter > delay {
+			delay = statusErr.RetryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Events 以 channel 方式订阅事件流，channel 在订阅结束时关闭。
+// 调用方需及时消费，否则会阻塞读取。
+func (c *Client) Events(ctx context.Context) <-chan Event {
+	ch := make(chan Event, 64)
+	go func() {
+		defer close(ch)
+		err := c.Subscribe(ctx, func(ev Event) {
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil && ctx.Err() == nil && c.OnError != nil {
+			c.OnError(err)
+		}
+	}()
+	return ch
+}
+
+// backoff 计算第 failures 次连续失败后的等待时间。流正常中断后（failures 为 0）
+// 按 retry 间隔重连，之后每次失败翻倍，直到 MaxBackoff。
+func (c *Client) backoff(failures int) time.Duration {
+	c.mu.Lock()
+	delay := c.retry
+	c.mu.Unlock()
+	if delay <= 0 {
+		delay = c.InitialBackoff
+	}
+	if delay <= 0 {
+		delay = DefaultInitialBackoff
+	}
+	max := c.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+	for i := 0; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// connect 建立一次连接并持续读取直到流结束，connected 表示是否成功建立了事件流
+func (c *Client) connect(ctx context.Context, handler func(Event)) (connected bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if id := c.LastEventID(); id != "" {
+		req.Header.Set("Last-Event-ID", id)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, &StatusError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		return false, fmt.Errorf("%w: %q", ErrUnexpectedContentType, ct)
+	}
+	if c.OnConnect != nil {
+		c.OnConnect()
+	}
+
+	dec := NewDecoder(resp.Body)
+	dec.lastEventID = c.LastEventID()
+	for {
+		ev, err := dec.Next()
+		c.mu.Lock()
+		c.lastEventID = dec.LastEventID()
+		if dec.Retry() > 0 {
+			c.retry = dec.Retry()
+		}
+		c.mu.Unlock()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return true, err
+		}
+		handler(ev)
+	}
+}
+
+func parseRetryAfter(v string) time.Duration {
+	secs, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
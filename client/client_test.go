@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sseserver "github.com/xinjiayu/sse"
+)
+
+func TestClientReconnectWithLastEventID(t *testing.T) {
+	var attempts int32
+	var mu sync.Mutex
+	var lastIDs []string
+	var gaps []time.Duration
+	var lastEnd time.Time
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		if !lastEnd.IsZero() {
+			gaps = append(gaps, time.Since(lastEnd))
+		}
+		mu.Unlock()
+
+		n := atomic.AddInt32(&attempts, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n == 1 {
+			w.Write([]byte("retry:50\n\n"))
+			w.Write(sseserver.SSEMessage{ID: "1", Event: "tick", Data: []byte("a")}.Bytes())
+		} else {
+			w.Write(sseserver.SSEMessage{ID: "2", Event: "tick", Data: []byte("b")}.Bytes())
+		}
+		mu.Lock()
+		lastEnd = time.Now()
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := New(ts.URL)
+	c.InitialBackoff = time.Second
+	var got []string
+	for ev := range c.Events(ctx) {
+		got = append(got, string(ev.Data))
+		if len(got) == 2 {
+			cancel()
+		}
+	}
+
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("收到的事件错误: %v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if lastIDs[0] != "" || lastIDs[1] != "1" {
+		t.Errorf("重连时未携带 Last-Event-ID，得到 %v", lastIDs)
+	}
+	// 服务端下发的 retry:50 应取代 1s 的 InitialBackoff
+	if gaps[0] > 500*time.Millisecond {
+		t.Errorf("未按 retry 间隔重连，等待了 %v", gaps[0])
+	}
+}
+
+func TestClientPermanentError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := New(ts.URL).Subscribe(ctx, func(Event) {})
+	statusErr, ok := err.(*StatusError)
+	if !ok || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("403 应作为永久错误返回，得到 %v", err)
+	}
+}
+
+func TestClientWithServer(t *testing.T) {
+	server := sseserver.NewServer()
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := New(ts.URL + "/subscribe/sysenv/update")
+	connected := make(chan struct{}, 1)
+	c.OnConnect = func() { connected <- struct{}{} }
+	events := c.Events(ctx)
+
+	select {
+	case <-connected:
+	case <-ctx.Done():
+		t.Fatal("连接超时")
+	}
+	for server.GetActiveConnectionCount() != 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	server.Broadcast <- sseserver.SSEMessage{Event: "env", Data: []byte("a\nb"), Namespace: "/sysenv/update"}
+
+	ev := <-events
+	if ev.Event != "env" || ev.Namespace != "/sysenv/update" || string(ev.Data) != "a\nb" {
+		t.Errorf("收到的事件错误: %+v", ev)
+	}
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	var attempts []time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts = append(attempts, time.Now())
+		n := len(attempts)
+		mu.Unlock()
+		if n == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := New(ts.URL)
+	c.InitialBackoff = 10 * time.Millisecond
+	if err := c.Subscribe(ctx, func(Event) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 2 || attempts[1].Sub(attempts[0]) < time.Second {
+		t.Errorf("未按 Retry-After 等待后重连: %v", attempts)
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Event 是从事件流中解析出的一条消息，字段与 sseserver.SSEMessage 对应
+type Event struct {
+	ID        string
+	Event     string
+	Data      []byte
+	Namespace string
+	Retry     time.Duration
+}
+
+// Decoder 按 SSE 规范解析事件流，额外支持服务端的 namespace: 字段。
+// 行尾支持 "\n" 与 "\r\n"。
+type Decoder struct {
+	r           *bufio.Reader
+	lastEventID string
+	retry       time.Duration
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// LastEventID 返回最近一次收到的事件 ID，规范要求其在事件之间保持
+func (d *Decoder) LastEventID() string {
+	return d.lastEventID
+}
+
+// Retry 返回服务端最近一次通过 retry: 下发的重连间隔，未下发时为 0
+func (d *Decoder) Retry() time.Duration {
+	return d.retry
+}
+
+// Next 读取下一条事件。只包含注释、retry 等字段而没有 data 的块不会作为事件返回。
+func (d *Decoder) Next() (Event, error) {
+	var ev Event
+	var data []byte
+	hasData := false
+
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if err != nil {
+			// 流在事件中途结束时丢弃未完成的事件
+			return Event{}, err
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		line = bytes.TrimSuffix(line, []byte("\r"))
+
+		if len(line) == 0 {
+			if !hasData {
+				ev = Event{}
+				continue
+			}
+			ev.ID = d.lastEventID
+			ev.Data = data
+			return ev, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			value = bytes.TrimPrefix(value, []byte(" "))
+		}
+
+		switch string(field) {
+		case "event":
+			ev.Event = string(value)
+		case "data":
+			if hasData {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				d.lastEventID = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+				ev.Retry = d.retry
+			}
+		case "namespace":
+			ev.Namespace = string(value)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	sseserver "github.com/xinjiayu/sse"
+)
+
+func TestDecoderServerFormat(t *testing.T) {
+	msgs := []sseserver.SSEMessage{
+		{ID: "1", Event: "env", Data: []byte(`{"a":1}`), Namespace: "/sysenv/update"},
+		{Data: []byte("line1\nline2\nline3")},
+		{Event: "empty", Data: []byte{}},
+		{ID: "2", Data: []byte("x"), Retry: 1500 * time.Millisecond},
+	}
+	var stream bytes.Buffer
+	stream.WriteString(":keepalive\n\n")
+	for _, msg := range msgs {
+		stream.Write(msg.Bytes())
+	}
+
+	dec := NewDecoder(&stream)
+	for i, want := range msgs {
+		got, err := dec.Next()
+		if err != nil {
+			t.Fatalf("第 %d 条事件解析失败: %v", i, err)
+		}
+		if got.Event != want.Event || got.Namespace != want.Namespace || !bytes.Equal(got.Data, want.Data) {
+			t.Errorf("第 %d 条事件不匹配，得到 %+v，想要 %+v", i, got, want)
+		}
+		if got.Retry != want.Retry {
+			t.Errorf("第 %d 条事件 retry 不匹配，得到 %v，想要 %v", i, got.Retry, want.Retry)
+		}
+	}
+	if dec.LastEventID() != "2" {
+		t.Errorf("LastEventID 错误，得到 %q，想要 2", dec.LastEventID())
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("流结束时应返回 io.EOF，得到 %v", err)
+	}
+}
+
+func TestDecoderSpecDetails(t *testing.T) {
+	stream := "retry:2000\r\n\r\n" +
+		"data: leading space\r\n\r\n" +
+		"id:7\nevent:no-data\n\n" +
+		"data\n\n"
+	dec := NewDecoder(strings.NewReader(stream))
+
+	ev, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ev.Data) != "leading space" {
+		t.Errorf("冒号后的单个空格应被去除，得到 %q", ev.Data)
+	}
+	if dec.Retry() != 2*time.Second {
+		t.Errorf("单独的 retry 块未生效，得到 %v", dec.Retry())
+	}
+
+	ev, err = dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Event != "" || len(ev.Data) != 0 || ev.ID != "7" {
+		t.Errorf("没有 data 的块不应派发，且 id 应保持，得到 %+v", ev)
+	}
+}
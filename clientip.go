@@ -0,0 +1,131 @@
+package sseserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies 解析 TrustedProxies 中的 IP 与 CIDR，单个 IP 视为只包含该地址的网段
+func parseTrustedProxies(entries []string) ([]*net.IPNet, []string) {
+	var nets []*net.IPNet
+	var invalid []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				invalid = append(invalid, entry)
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, invalid
+}
+
+func (s *Server) trustedProxy(ip net.IP) bool {
+	for _, n := range s.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP 返回请求的客户端 IP，用于日志与连接数限制。
+// 只有对端地址属于 TrustedProxies 时才采用代理转发的地址，依次查看 Forwarded（RFC 7239）、
+// X-Forwarded-For 与 X-Real-IP。转发链从右向左逐跳检查，跳过可信代理，第一个不可信的地址即为客户端：
+// 链的左侧可以由客户端任意填写，只有可信代理追加的部分是可靠的。
+func (s *Server) clientIP(r *http.Request) string {
+	peer := extractIP(r.RemoteAddr)
+	peerIP := net.ParseIP(peer)
+	if peerIP == nil || !s.trustedProxy(peerIP) {
+		return peer
+	}
+	if hops := forwardedFor(r.Header.Values("Forwarded")); len(hops) > 0 {
+		return s.resolveHops(hops, peer)
+	}
+	if hops := forwardedHops(r.Header.Values("X-Forwarded-For")); len(hops) > 0 {
+		return s.resolveHops(hops, peer)
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return peer
+}
+
+// resolveHops 从右向左跳过可信代理，返回第一个不可信的地址。
+// 遇到无法解析的地址（如 Forwarded 中的 unknown 或混淆标识）时无法继续判断，返回最近一个可信代理；
+// 全部为可信代理时返回最左侧的地址。
+func (s *Server) resolveHops(hops []string, peer string) string {
+	last := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			return last
+		}
+		last = ip.String()
+		if !s.trustedProxy(ip) {
+			return last
+		}
+	}
+	return last
+}
+
+// forwardedHops 将 X-Forwarded-For 的各个头与逗号分隔的地址按顺序展开
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, stripPort(hop))
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedFor 按顺序提取 Forwarded 头中各元素的 for 参数，例如
+//
+//	Forwarded: for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]:4711"
+//
+// 缺少 for 参数的元素记为空字符串，以便在逐跳检查时视为无法解析。
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+			node := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					node = stripPort(strings.Trim(value, `"`))
+				}
+			}
+			hops = append(hops, node)
+		}
+	}
+	return hops
+}
+
+// stripPort 去掉地址中的端口与 IPv6 的方括号
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
@@ -0,0 +1,63 @@
+package sseserver
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	server := NewServer(ServerOptions{TrustedProxies: []string{"10.0.0.0/8", "2001:db8::1", "bogus"}})
+	defer server.Stop()
+	if len(server.trustedProxies) != 2 {
+		t.Fatalf("可信代理解析错误: %v", server.trustedProxies)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"直连忽略转发头", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Real-IP": "127.0.0.1"}, "203.0.113.7"},
+		{"可信代理无转发头", "10.0.0.2:5000", nil, "10.0.0.2"},
+		{"XFF 单跳", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "198.51.100.9"}, "198.51.100.9"},
+		{"XFF 从右向左跳过可信代理", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.9, 10.1.2.3"}, "198.51.100.9"},
+		{"XFF 全部可信", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "10.9.9.9, 10.1.2.3"}, "10.9.9.9"},
+		{"XFF 带端口", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "198.51.100.9:1234"}, "198.51.100.9"},
+		{"XFF 无法解析时取最近的可信代理", "10.0.0.2:5000", map[string]string{"X-Forwarded-For": "1.1.1.1, garbage, 10.1.2.3"}, "10.1.2.3"},
+		{"Forwarded 优先", "10.0.0.2:5000", map[string]string{"Forwarded": `for=192.0.2.60;proto=https, for=10.3.3.3`, "X-Forwarded-For": "6.6.6.6"}, "192.0.2.60"},
+		{"Forwarded IPv6 带端口", "[2001:db8::1]:443", map[string]string{"Forwarded": `For="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"Forwarded unknown", "10.0.0.2:5000", map[string]string{"Forwarded": "for=unknown"}, "10.0.0.2"},
+		{"X-Real-IP", "10.0.0.2:5000", map[string]string{"X-Real-IP": " 198.51.100.9 "}, "198.51.100.9"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/subscribe/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		if got := server.clientIP(r); got != tt.want {
+			t.Errorf("%s: 得到 %s，想要 %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrustedProxyLimits(t *testing.T) {
+	server := NewServer(ServerOptions{TrustedProxies: []string{"127.0.0.1"}, MaxConnectionsPerIP: 1})
+	defer server.Stop()
+
+	r := httptest.NewRequest("GET", "/subscribe/", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.9")
+
+	// 单 IP 限制按解析出的客户端 IP 计数，伪造的 X-Forwarded-For 前缀不能绕过
+	slot, rejected := server.admit(server.clientIP(r), "", []string{""})
+	if rejected != nil {
+		t.Fatal("首个连接不应被拒绝")
+	}
+	defer slot.release()
+	r.Header.Set("X-Forwarded-For", "6.6.6.6, 198.51.100.9")
+	if _, rejected := server.admit(server.clientIP(r), "", []string{""}); rejected == nil || rejected.reason != rejectIP {
+		t.Errorf("伪造转发链的同一客户端应受单 IP 限制")
+	}
+}
@@ -0,0 +1,169 @@
+package sseserver
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 压缩器占用的内存较大，按编码分别复用
+var (
+	gzipWriterPool = sync.Pool{New: func() any {
+		zw, _ := gzip.NewWriterLevel(io.Discard, gzip.BestSpeed)
+		return zw
+	}}
+	zlibWriterPool = sync.Pool{New: func() any {
+		zw, _ := zlib.NewWriterLevel(io.Discard, zlib.BestSpeed)
+		return zw
+	}}
+)
+
+// flushWriter 是可以 Reset 到新输出并在 Flush 时输出已压缩数据的压缩器
+type flushWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriter 包装事件流，每次 Flush 时先冲刷压缩器再冲刷底层连接，保证每帧及时送达
+type compressWriter struct {
+	zw      flushWriter
+	pool    *sync.Pool
+	flusher http.Flusher
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	return c.zw.Write(p)
+}
+
+func (c *compressWriter) Flush() {
+	if err := c.zw.Flush(); err != nil {
+		return
+	}
+	c.flusher.Flush()
+}
+
+// Close 写入压缩流结尾并归还压缩器
+func (c *compressWriter) Close() error {
+	err := c.zw.Close()
+	c.zw.Reset(io.Discard)
+	c.pool.Put(c.zw)
+	return err
+}
+
+// negotiateEncoding 从 Accept-Encoding 中选择压缩编码，优先 gzip，不支持时返回空字符串
+func negotiateEncoding(header string) string {
+	var gzipOK, deflateOK bool
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := parseQValue(params); ok && q == 0 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip":
+			gzipOK = true
+		case "deflate":
+			deflateOK = true
+		}
+	}
+	switch {
+	case gzipOK:
+		return "gzip"
+	case deflateOK:
+		return "deflate"
+	}
+	return ""
+}
+
+func parseQValue(params string) (float64, bool) {
+	for _, p := range strings.Split(params, ";") {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "q=") {
+			q, err := strconv.ParseFloat(p[2:], 64)
+			return q, err == nil
+		}
+	}
+	return 0, false
+}
+
+// newCompressWriter 在启用压缩且客户端支持时设置 Content-Encoding 并返回压缩写入器，必须在发送响应头之前调用。
+// 配置了 CompressionMinSize 时，若连接订阅的 namespace 近期广播消息的平均大小低于该值则不压缩：
+// 每次 Flush 都会带来额外的压缩块开销，小消息压缩后反而可能更大。
+func (s *Server) newCompressWriter(w http.ResponseWriter, r *http.Request, flusher http.Flusher, namespaces []string) *compressWriter {
+	if !s.Options.Compression {
+		return nil
+	}
+	if min := s.Options.CompressionMinSize; min > 0 {
+		if avg := s.hub.expectedFrameSize(namespaces); avg > 0 && avg < uint64(min) {
+			return nil
+		}
+	}
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return nil
+	}
+
+	pool := &gzipWriterPool
+	if encoding == "deflate" {
+		pool = &zlibWriterPool
+	}
+	zw := pool.Get().(flushWriter)
+	zw.Reset(w)
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressWriter{zw: zw, pool: pool, flusher: flusher}
+}
+
+// maxTrackedNamespaces 是单独统计消息大小的 namespace 数上限，超出后新的 namespace 只计入全局平均
+const maxTrackedNamespaces = 4096
+
+// observeFrameSize 以指数移动平均分别记录全局与消息所属 namespace 的广播消息大小，
+// 用于判断新连接是否值得压缩
+func (h *hub) observeFrameSize(namespace string, n int) {
+	updateAverage(&h.avgFrameSize, n)
+	v, ok := h.frameSizes.Load(namespace)
+	if !ok {
+		if atomic.LoadInt32(&h.frameSizeCount) >= maxTrackedNamespaces {
+			return
+		}
+		var loaded bool
+		if v, loaded = h.frameSizes.LoadOrStore(namespace, new(uint64)); !loaded {
+			atomic.AddInt32(&h.frameSizeCount, 1)
+		}
+	}
+	updateAverage(v.(*uint64), n)
+}
+
+// updateAverage 更新指数移动平均，并发更新可能丢失个别样本，对平均值的影响可以忽略
+func updateAverage(p *uint64, n int) {
+	avg := atomic.LoadUint64(p)
+	if avg == 0 {
+		avg = uint64(n)
+	} else {
+		avg = avg - avg/8 + uint64(n)/8
+	}
+	atomic.StoreUint64(p, avg)
+}
+
+// expectedFrameSize 估计订阅 patterns 的连接将收到的消息大小：取匹配的各 namespace（包括发给所有连接的
+// 空 namespace）平均大小中的最大值。订阅全部消息或 namespace 未单独统计时使用全局平均，没有样本时返回 0。
+func (h *hub) expectedFrameSize(patterns []string) uint64 {
+	if containsNamespace(patterns, "") || atomic.LoadInt32(&h.frameSizeCount) >= maxTrackedNamespaces {
+		return atomic.LoadUint64(&h.avgFrameSize)
+	}
+	var max uint64
+	h.frameSizes.Range(func(key, value any) bool {
+		if ns := key.(string); ns == "" || matchesAny(patterns, ns) {
+			if avg := atomic.LoadUint64(value.(*uint64)); avg > max {
+				max = avg
+			}
+		}
+		return true
+	})
+	return max
+}
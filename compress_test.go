@@ -0,0 +1,111 @@
+package sseserver
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                       "",
+		"gzip":                   "gzip",
+		"deflate, gzip;q=0.5":    "gzip",
+		"gzip;q=0, deflate":      "deflate",
+		"br, identity":           "",
+		" GZIP ; q=1.0, deflate": "gzip",
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q，想要 %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressedStream(t *testing.T) {
+	server := NewServer(ServerOptions{Compression: true})
+
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/subscribe/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding 错误，得到 %q", got)
+	}
+
+	waitUntil(t, time.Second, func() bool {
+		return server.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+	data := `{"env":"` + strings.Repeat("x", 512) + `"}`
+	server.Broadcast <- SSEMessage{Event: "env", Data: []byte(data)}
+
+	// 每帧都会冲刷压缩器，无需等待连接关闭即可解压出完整的帧
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(zr)
+	var lines []string
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取压缩流失败: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	if got, want := strings.Join(lines, ""), "event:env\ndata:"+data+"\n\n"; got != want {
+		t.Errorf("解压后的帧错误，得到 %q", got)
+	}
+}
+
+func TestCompressionMinSize(t *testing.T) {
+	server := NewServer(ServerOptions{Compression: true, CompressionMinSize: 256})
+	defer server.Stop()
+	server.hub.observeFrameSize("/ticks", 64)
+
+	compressed := func(namespaces ...string) bool {
+		req := httptest.NewRequest("GET", "/subscribe/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		cw := server.newCompressWriter(w, req, w, namespaces)
+		if cw == nil {
+			return false
+		}
+		cw.Close()
+		return w.Header().Get("Content-Encoding") == "gzip"
+	}
+	if compressed("") {
+		t.Errorf("平均消息小于阈值时不应压缩")
+	}
+
+	for i := 0; i < 64; i++ {
+		server.hub.observeFrameSize("/reports", 1024)
+	}
+	if !compressed("/reports") || !compressed("") {
+		t.Errorf("平均消息超过阈值时应压缩")
+	}
+	// 按连接订阅的 namespace 判断，其它 namespace 的大消息不影响小消息的订阅者
+	if compressed("/ticks") {
+		t.Errorf("订阅的 namespace 消息较小时不应压缩")
+	}
+	if !compressed("/ticks", "/reports/#") {
+		t.Errorf("订阅的任一 namespace 消息较大时应压缩")
+	}
+	if !compressed("/unknown") {
+		t.Errorf("没有样本的 namespace 应压缩")
+	}
+}
@@ -1,32 +1,39 @@
 package sseserver
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const ConnectionTimeout = 30 * time.Minute
 
 type connection struct {
-	send         chan []byte
+	send         chan *frame
 	hub          *hub
+	id           string // 连接 ID，随机生成，不可猜测
+	userID       string // 由 ServerOptions.UserIDFunc 解析出的用户 ID，可为空
+	principal    Principal
+	remoteIP     string
+	filter       *connFilter    // 为 nil 时接收全部消息
+	namespaces   []string       // 订阅的 namespace，空字符串表示订阅全部消息，由所在分片的锁保护
+	lastEventID  string         // 客户端重连时携带的 Last-Event-ID
+	replayedSeq  uint64         // 注册时已补发到的消息存储序号
+	closeReason  int32          // 断开原因加 1，0 表示未记录，见 setCloseReason
+	admission    *admissionSlot // 准入占用的名额，动态订阅经此检查单 namespace 上限；直接注册到 hub 的连接为 nil
 	createdAt    time.Time
 	lastActivity time.Time
 	mu           sync.Mutex
-	closed       bool      // 标记 send channel 是否已关闭
+	closed       bool // 标记 send channel 是否已关闭
+	replaying    bool // 正在补发历史消息，期间投递的帧暂存在 pending，由 mutex 保护
+	pending      []*frame
 	closeOnce    sync.Once // 确保 channel 只关闭一次
 }
 
-// reset 重置连接状态，用于从 sync.Pool 复用时初始化
-// 必须在获取连接后、使用前调用
-func (c *connection) reset() {
-	c.mu.Lock()
-	c.closed = false
-	c.mu.Unlock()
-	// 重置 closeOnce，使其可以再次执行
-	c.closeOnce = sync.Once{}
-}
-
 func (c *connection) updateActivity() {
 	c.mu.Lock()
 	c.lastActivity = time.Now()
@@ -50,6 +57,10 @@ func (c *connection) safeClose() {
 		c.mu.Lock()
 		c.closed = true
 		close(c.send)
+		for _, f := range c.pending {
+			f.release()
+		}
+		c.pending = nil
 		c.mu.Unlock()
 	})
 }
@@ -70,9 +81,54 @@ func (c *connection) trySend(data []byte) bool {
 		return false
 	}
 	select {
-	case c.send <- data:
+	case c.send <- newFrame(data):
 		return true
 	default:
 		return false
 	}
 }
+
+// finishReplay 发送补发的历史消息，随后按顺序放入补发期间暂存的帧并恢复直接投递
+func (c *connection) finishReplay(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := c.pending
+	c.pending, c.replaying = nil, false
+	if c.closed {
+		return
+	}
+	if len(data) > 0 {
+		select {
+		case c.send <- newFrame(data):
+		default:
+		}
+	}
+	for _, f := range pending {
+		select {
+		case c.send <- f:
+		default:
+			f.release()
+		}
+	}
+}
+
+// normalizeNamespace 规范化 namespace：保证以 "/" 开头且不以 "/" 结尾，
+// 根路径 "/" 与空字符串都规范化为 ""。
+func normalizeNamespace(ns string) string {
+	ns = strings.Trim(ns, "/")
+	if ns == "" {
+		return ""
+	}
+	return "/" + ns
+}
+
+var connIDFallback uint64
+
+// newConnectionID 生成随机连接 ID，随机源不可用时退化为递增序号
+func newConnectionID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "c" + strconv.FormatUint(atomic.AddUint64(&connIDFallback, 1), 10)
+	}
+	return hex.EncodeToString(b[:])
+}
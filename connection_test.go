@@ -46,8 +46,8 @@ func TestConnectionWrite(t *testing.T) {
 
 	select {
 	case receivedMsg := <-conn.send:
-		if string(receivedMsg) != string(message) {
-			t.Errorf("写入的消息不匹配，得到 %s，想要 %s", string(receivedMsg), string(message))
+		if string(receivedMsg.buf) != string(message) {
+			t.Errorf("写入的消息不匹配，得到 %s，想要 %s", string(receivedMsg.buf), string(message))
 		}
 	case <-time.After(time.Second):
 		t.Error("写入消息超时")
@@ -0,0 +1,389 @@
+package sseserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentExt         = ".seg"
+	defaultSegmentSize = 16 << 20
+)
+
+var errStoreClosed = errors.New("sse: message store closed")
+
+// FileStoreOptions 配置文件消息存储的分段与保留策略，保留条件为 0 表示不限制。
+// 清理以分段为单位，实际保留量可能略超出限制；超过 MaxAge 的消息在读取时总会被跳过。
+type FileStoreOptions struct {
+	SegmentSize int64         // 单个分段文件的大小上限，0 = 默认 16MB
+	MaxMessages int           // 最多保留的消息数
+	MaxAge      time.Duration // 消息最长保留时间
+	MaxBytes    int64         // 全部分段的总大小上限
+}
+
+// FileStore 是 MessageStore 的文件实现：消息以 JSON 行追加写入目录下的分段文件，
+// 进程重启后从已有分段恢复，写到一半的末尾记录会被截断。
+// 内存中为保留的每条消息记录 ID 所在的位置，补发时直接从该位置读起。
+type FileStore struct {
+	mu       sync.Mutex
+	dir      string
+	opts     FileStoreOptions
+	segments []*segment // 按写入顺序排列，最后一个为当前写入的分段
+	index    map[string]recordPos
+	active   *os.File
+	nextSeg  uint64
+}
+
+// recordPos 是一条记录所在的分段及该记录结束处的偏移量
+type recordPos struct {
+	seg *segment
+	end int64
+}
+
+type segment struct {
+	path     string
+	count    int
+	size     int64
+	lastTime time.Time
+}
+
+// storeRecord 是分段文件中的一行
+type storeRecord struct {
+	Time      int64  `json:"t"` // UnixNano
+	ID        string `json:"id,omitempty"`
+	Event     string `json:"event,omitempty"`
+	Namespace string `json:"ns,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+	Retry     int64  `json:"retry,omitempty"` // 毫秒
+}
+
+func (r storeRecord) message() SSEMessage {
+	return SSEMessage{
+		ID:        r.ID,
+		Event:     r.Event,
+		Namespace: r.Namespace,
+		Data:      r.Data,
+		Retry:     time.Duration(r.Retry) * time.Millisecond,
+	}
+}
+
+// NewFileStore 打开（必要时创建）dir 下的文件消息存储
+func NewFileStore(dir string, opts FileStoreOptions) (*FileStore, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = defaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	fs := &FileStore{dir: dir, opts: opts, index: make(map[string]recordPos)}
+	for _, name := range names {
+		seg, err := loadSegment(name, fs.index)
+		if err != nil {
+			return nil, err
+		}
+		fs.segments = append(fs.segments, seg)
+		n, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), segmentExt), 10, 64)
+		if err == nil && n >= fs.nextSeg {
+			fs.nextSeg = n + 1
+		}
+	}
+
+	if last := fs.lastSegment(); last != nil && !fs.full(last) {
+		fs.active, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	} else {
+		err = fs.rotateLocked()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.trimLocked(time.Now()); err != nil {
+		fs.active.Close()
+		return nil, err
+	}
+	return fs, nil
+}
+
+// loadSegment 统计分段中的完整记录并记入 index，截断末尾未写完的记录
+func loadSegment(path string, index map[string]recordPos) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seg := &segment{path: path}
+	err = eachRecord(f, func(rec storeRecord, size int) {
+		seg.count++
+		seg.size += int64(size)
+		seg.lastTime = time.Unix(0, rec.Time)
+		if rec.ID != "" {
+			index[rec.ID] = recordPos{seg: seg, end: seg.size}
+		}
+	}, func(size int) {
+		seg.size += int64(size)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() > seg.size {
+		if err := f.Truncate(seg.size); err != nil {
+			return nil, err
+		}
+	}
+	return seg, nil
+}
+
+// eachRecord 依次读取完整的记录行，无法解析的行交给 skip，末尾没有换行的不完整记录被忽略
+func eachRecord(r io.Reader, fn func(rec storeRecord, size int), skip func(size int)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var rec storeRecord
+		if json.Unmarshal(bytes.TrimSpace(line), &rec) != nil {
+			if skip != nil {
+				skip(len(line))
+			}
+			continue
+		}
+		fn(rec, len(line))
+	}
+}
+
+// Append 实现 MessageStore
+func (fs *FileStore) Append(msg SSEMessage) error {
+	now := time.Now()
+	line, err := json.Marshal(storeRecord{
+		Time:      now.UnixNano(),
+		ID:        msg.ID,
+		Event:     msg.Event,
+		Namespace: msg.Namespace,
+		Data:      msg.Data,
+		Retry:     msg.Retry.Milliseconds(),
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.active == nil {
+		return errStoreClosed
+	}
+	n, err := fs.active.Write(line)
+	seg := fs.lastSegment()
+	seg.size += int64(n)
+	if err != nil {
+		return err
+	}
+	seg.count++
+	seg.lastTime = now
+	if msg.ID != "" {
+		fs.index[msg.ID] = recordPos{seg: seg, end: seg.size}
+	}
+	if fs.full(seg) {
+		if err := fs.rotateLocked(); err != nil {
+			return err
+		}
+		return fs.trimLocked(now)
+	}
+	return nil
+}
+
+// ReadSince 实现 MessageStore。id 在存储中时从其所在位置读起，否则从最早的分段读起；
+// until 在存储中时读到其所在位置为止。
+// 文件在锁外读取：当前分段末尾正在写入的记录没有换行，会被忽略；读取前被清理的分段直接跳过。
+func (fs *FileStore) ReadSince(patterns []string, id, until string) ([]SSEMessage, error) {
+	fs.mu.Lock()
+	first, last := 0, len(fs.segments)-1
+	var offset, end int64 = 0, -1
+	if pos, ok := fs.index[id]; ok && id != "" {
+		first, offset = fs.segmentIndex(pos.seg), pos.end
+	}
+	if until != "" {
+		pos, ok := fs.index[until]
+		if !ok {
+			fs.mu.Unlock()
+			return nil, nil
+		}
+		last, end = fs.segmentIndex(pos.seg), pos.end
+	}
+	if first > last || (first == last && end >= 0 && offset >= end) {
+		fs.mu.Unlock()
+		return nil, nil
+	}
+	segments := append([]*segment(nil), fs.segments[first:last+1]...)
+	fs.mu.Unlock()
+
+	var cutoff int64
+	if fs.opts.MaxAge > 0 {
+		cutoff = time.Now().Add(-fs.opts.MaxAge).UnixNano()
+	}
+	var msgs []SSEMessage
+	for i, seg := range segments {
+		f, err := os.Open(seg.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var start int64
+		if i == 0 && offset > 0 {
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				f.Close()
+				return nil, err
+			}
+			start = offset
+		}
+		var r io.Reader = f
+		if i == len(segments)-1 && end >= 0 {
+			r = io.LimitReader(f, end-start)
+		}
+		err = eachRecord(r, func(rec storeRecord, _ int) {
+			if rec.Time < cutoff {
+				return
+			}
+			if ns := normalizeNamespace(rec.Namespace); ns == "" || matchesAny(patterns, ns) {
+				msgs = append(msgs, rec.message())
+			}
+		}, nil)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
+
+// segmentIndex 返回分段在 fs.segments 中的位置，调用方需持有 mu。
+// index 中的分段总是仍在保留中，找不到时返回 0。
+func (fs *FileStore) segmentIndex(seg *segment) int {
+	for i, s := range fs.segments {
+		if s == seg {
+			return i
+		}
+	}
+	return 0
+}
+
+// Trim 实现 MessageStore，删除超出保留策略的最旧分段
+func (fs *FileStore) Trim() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.active == nil {
+		return errStoreClosed
+	}
+	return fs.trimLocked(time.Now())
+}
+
+// Close 关闭当前写入的分段，之后的 Append 返回错误
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.active == nil {
+		return nil
+	}
+	err := fs.active.Close()
+	fs.active = nil
+	return err
+}
+
+func (fs *FileStore) lastSegment() *segment {
+	if len(fs.segments) == 0 {
+		return nil
+	}
+	return fs.segments[len(fs.segments)-1]
+}
+
+// full 判断分段是否需要切换。按消息数保留时，分段最多容纳 MaxMessages 的四分之一，
+// 使清理粒度不至于过粗。
+func (fs *FileStore) full(seg *segment) bool {
+	if seg.size >= fs.opts.SegmentSize {
+		return true
+	}
+	return fs.opts.MaxMessages > 0 && seg.count >= (fs.opts.MaxMessages+3)/4
+}
+
+// rotateLocked 关闭当前分段并创建新的分段
+func (fs *FileStore) rotateLocked() error {
+	if fs.active != nil {
+		if err := fs.active.Close(); err != nil {
+			return err
+		}
+		fs.active = nil
+	}
+	path := filepath.Join(fs.dir, fmt.Sprintf("%020d%s", fs.nextSeg, segmentExt))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fs.nextSeg++
+	fs.active = f
+	fs.segments = append(fs.segments, &segment{path: path})
+	return nil
+}
+
+func (fs *FileStore) trimLocked(now time.Time) error {
+	var count int
+	var size int64
+	for _, seg := range fs.segments {
+		count += seg.count
+		size += seg.size
+	}
+	for len(fs.segments) > 0 {
+		seg := fs.segments[0]
+		if seg.count == 0 && len(fs.segments) == 1 {
+			break
+		}
+		expired := fs.opts.MaxAge > 0 && now.Sub(seg.lastTime) > fs.opts.MaxAge
+		overCount := fs.opts.MaxMessages > 0 && count > fs.opts.MaxMessages
+		overSize := fs.opts.MaxBytes > 0 && size > fs.opts.MaxBytes
+		if seg.count > 0 && !expired && !overCount && !overSize {
+			break
+		}
+		if len(fs.segments) == 1 {
+			// 当前写入的分段也需要删除时，先切换到新分段
+			if err := fs.rotateLocked(); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if seg.count > 0 {
+			for id, pos := range fs.index {
+				if pos.seg == seg {
+					delete(fs.index, id)
+				}
+			}
+		}
+		fs.segments = fs.segments[1:]
+		count -= seg.count
+		size -= seg.size
+	}
+	return nil
+}
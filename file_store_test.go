@@ -0,0 +1,137 @@
+package sseserver
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func storeIDs(t *testing.T, store MessageStore, namespace, id string) string {
+	t.Helper()
+	return storeRange(t, store, namespace, id, "")
+}
+
+func storeRange(t *testing.T, store MessageStore, namespace, id, until string) string {
+	t.Helper()
+	msgs, err := store.ReadSince([]string{namespace}, id, until)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, FileStoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Append(SSEMessage{ID: "1", Namespace: "/a", Data: []byte("a\nb")})
+	store.Append(SSEMessage{ID: "2", Namespace: "/b", Data: []byte("b")})
+	store.Append(SSEMessage{ID: "3", Data: []byte("global"), Retry: time.Second})
+	store.Append(SSEMessage{ID: "4", Namespace: "/a/x", Event: "e", Data: []byte("x")})
+	store.Close()
+
+	// 模拟进程在写入过程中退出，末尾留下不完整的记录
+	names, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	f, _ := os.OpenFile(names[len(names)-1], os.O_WRONLY|os.O_APPEND, 0o644)
+	f.WriteString(`{"t":1,"id":"5"`)
+	f.Close()
+
+	store, err = NewFileStore(dir, FileStoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if got := storeIDs(t, store, "/a/#", "1"); got != "3,4" {
+		t.Errorf("重启后补发错误，得到 %s，想要 3,4", got)
+	}
+	if got := storeIDs(t, store, "/b", "unknown"); got != "2,3" {
+		t.Errorf("未知 ID 应返回全部保留的消息，得到 %s", got)
+	}
+	msgs, _ := store.ReadSince([]string{"/a/x"}, "3", "")
+	if len(msgs) != 1 || string(msgs[0].Bytes()) != "id:4\nevent:e\nnamespace:/a/x\ndata:x\n\n" {
+		t.Errorf("消息内容未完整保存: %+v", msgs)
+	}
+
+	store.Append(SSEMessage{ID: "5", Data: []byte("after")})
+	if got := storeIDs(t, store, "", "3"); got != "4,5" {
+		t.Errorf("截断不完整记录后追加错误，得到 %s", got)
+	}
+}
+
+func TestFileStoreRetention(t *testing.T) {
+	store, err := NewFileStore(t.TempDir(), FileStoreOptions{MaxMessages: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for i := 1; i <= 20; i++ {
+		store.Append(SSEMessage{ID: strconv.Itoa(i), Data: []byte("x")})
+	}
+	if got := storeIDs(t, store, "", ""); got != "13,14,15,16,17,18,19,20" {
+		t.Errorf("按消息数保留错误，得到 %s", got)
+	}
+
+	// 被清理的消息不再可定位，按未知 ID 处理
+	if got := storeIDs(t, store, "", "3"); got != "13,14,15,16,17,18,19,20" {
+		t.Errorf("已清理的 ID 应按未知 ID 处理，得到 %s", got)
+	}
+	if got := storeIDs(t, store, "", "15"); got != "16,17,18,19,20" {
+		t.Errorf("按索引定位 ID 错误，得到 %s", got)
+	}
+
+	// until 限定读取的终点，跨分段时在其所在位置停止
+	if got := storeRange(t, store, "", "14", "18"); got != "15,16,17,18" {
+		t.Errorf("读到 until 为止错误，得到 %s", got)
+	}
+	if got := storeRange(t, store, "", "unknown", "13"); got != "13" {
+		t.Errorf("until 为第一条时错误，得到 %s", got)
+	}
+	if got := storeRange(t, store, "", "18", "18"); got != "" {
+		t.Errorf("id 与 until 相同时不应返回消息，得到 %s", got)
+	}
+	if got := storeRange(t, store, "", "18", "16"); got != "" {
+		t.Errorf("until 早于 id 时不应返回消息，得到 %s", got)
+	}
+	if got := storeRange(t, store, "", "", "3"); got != "" {
+		t.Errorf("until 不在存储中时不应返回消息，得到 %s", got)
+	}
+	if n := len(store.index); n != 8 {
+		t.Errorf("索引应只包含保留的 8 条消息，得到 %d", n)
+	}
+
+	store.opts.MaxAge = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	if err := store.Trim(); err != nil {
+		t.Fatal(err)
+	}
+	if got := storeIDs(t, store, "", ""); got != "" {
+		t.Errorf("过期消息未清理，得到 %s", got)
+	}
+	if len(store.segments) != 1 {
+		t.Errorf("清理后应只剩当前分段，得到 %d 个", len(store.segments))
+	}
+}
+
+func TestMessageStoreSeedsID(t *testing.T) {
+	store := NewMemoryStore(10)
+	store.Append(SSEMessage{ID: "node1-41", Data: []byte("x")})
+
+	server := NewServer(ServerOptions{MessageStore: store})
+	defer server.Stop()
+
+	msg := SSEMessage{Data: []byte("y")}
+	server.hub.assignID(&msg)
+	if msg.ID != "42" {
+		t.Errorf("ID 计数未从存储恢复，得到 %s，想要 42", msg.ID)
+	}
+}
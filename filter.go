@@ -0,0 +1,129 @@
+package sseserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// connFilter 是连接通过查询参数声明的消息过滤条件：
+//
+//	?events=alarm,status          只接收指定事件，未命名事件用 message 表示
+//	?filter=level:2&filter=a.b:x  只接收 JSON data 中字段等于指定值的消息，多个条件需同时满足
+type connFilter struct {
+	events map[string]struct{}
+	fields []fieldFilter
+}
+
+type fieldFilter struct {
+	path  []string
+	value string
+}
+
+var errInvalidFilter = errors.New("filter must be in the form field:value")
+
+// parseConnFilter 从查询参数解析过滤条件，没有任何条件时返回 nil
+func parseConnFilter(q url.Values) (*connFilter, error) {
+	var f connFilter
+	for _, param := range q["events"] {
+		for _, event := range strings.Split(param, ",") {
+			event = strings.TrimSpace(event)
+			if event == "" {
+				continue
+			}
+			if f.events == nil {
+				f.events = make(map[string]struct{})
+			}
+			f.events[event] = struct{}{}
+		}
+	}
+	for _, param := range q["filter"] {
+		i := strings.IndexByte(param, ':')
+		if i <= 0 {
+			return nil, errInvalidFilter
+		}
+		f.fields = append(f.fields, fieldFilter{
+			path:  strings.Split(param[:i], "."),
+			value: param[i+1:],
+		})
+	}
+	if f.events == nil && f.fields == nil {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// match 判断消息是否满足过滤条件，data 用于在多个连接之间共享 JSON 解析结果
+func (f *connFilter) match(msg *SSEMessage, data *lazyJSON) bool {
+	if f == nil {
+		return true
+	}
+	if f.events != nil {
+		event := msg.Event
+		if event == "" {
+			event = "message"
+		}
+		if _, ok := f.events[event]; !ok {
+			return false
+		}
+	}
+	if len(f.fields) == 0 {
+		return true
+	}
+	obj := data.object()
+	if obj == nil {
+		return false
+	}
+	for _, ff := range f.fields {
+		if !ff.match(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ff fieldFilter) match(obj map[string]any) bool {
+	var v any = obj
+	for _, key := range ff.path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return false
+		}
+		if v, ok = m[key]; !ok {
+			return false
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v == ff.value
+	case json.Number:
+		return v.String() == ff.value
+	case bool:
+		return (v && ff.value == "true") || (!v && ff.value == "false")
+	case nil:
+		return ff.value == "null"
+	}
+	return false
+}
+
+// lazyJSON 在第一次需要时才把消息 data 解析为 JSON 对象，每条消息只解析一次，
+// 可在投递同一条消息的多个分片 worker 之间共享
+type lazyJSON struct {
+	raw  []byte
+	once sync.Once
+	obj  map[string]any
+}
+
+func (l *lazyJSON) object() map[string]any {
+	l.once.Do(func() {
+		dec := json.NewDecoder(bytes.NewReader(l.raw))
+		dec.UseNumber()
+		if err := dec.Decode(&l.obj); err != nil {
+			l.obj = nil
+		}
+	})
+	return l.obj
+}
@@ -0,0 +1,81 @@
+package sseserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestConnFilterMatch(t *testing.T) {
+	q, _ := url.ParseQuery("events=alarm,message&filter=level:2&filter=device.id:42")
+	f, err := parseConnFilter(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		msg  SSEMessage
+		want bool
+	}{
+		{"事件与字段均匹配", SSEMessage{Event: "alarm", Data: []byte(`{"level":2,"device":{"id":"42"}}`)}, true},
+		{"未命名事件按 message 匹配", SSEMessage{Data: []byte(`{"level":2,"device":{"id":42}}`)}, true},
+		{"事件不匹配", SSEMessage{Event: "status", Data: []byte(`{"level":2,"device":{"id":"42"}}`)}, false},
+		{"字段值不匹配", SSEMessage{Event: "alarm", Data: []byte(`{"level":3,"device":{"id":"42"}}`)}, false},
+		{"字段缺失", SSEMessage{Event: "alarm", Data: []byte(`{"level":2}`)}, false},
+		{"非 JSON 数据", SSEMessage{Event: "alarm", Data: []byte("plain")}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := f.match(&tc.msg, &lazyJSON{raw: tc.msg.Data}); got != tc.want {
+				t.Errorf("match 结果错误，得到 %v，想要 %v", got, tc.want)
+			}
+		})
+	}
+
+	if f, _ := parseConnFilter(url.Values{}); f != nil {
+		t.Error("没有过滤参数时应返回 nil")
+	}
+	if _, err := parseConnFilter(url.Values{"filter": {"level"}}); err == nil {
+		t.Error("缺少冒号的 filter 应返回错误")
+	}
+}
+
+func TestHubBroadcastSkipsFilteredConnections(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+
+	alarms := h.newConnection()
+	alarms.filter = &connFilter{events: map[string]struct{}{"alarm": {}}}
+	all := h.newConnection()
+	h.registerConnection(alarms)
+	h.registerConnection(all)
+	waitUntil(t, time.Second, func() bool {
+		return h.GetActiveConnectionCount() == 2
+	}, "连接未注册")
+
+	h.broadcast <- SSEMessage{Event: "status", Data: []byte("ok")}
+	h.broadcast <- SSEMessage{Event: "alarm", Data: []byte("fire")}
+	time.Sleep(100 * time.Millisecond)
+
+	if got := drainFrames(alarms); len(got) != 1 || got[0] != "event:alarm\ndata:fire\n\n" {
+		t.Errorf("过滤后的连接收到的消息错误: %q", got)
+	}
+	if got := drainFrames(all); len(got) != 2 {
+		t.Errorf("未过滤的连接应收到全部消息，得到 %q", got)
+	}
+}
+
+func TestSubscribeInvalidFilter(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/subscribe/?filter=bad", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("非法 filter 应返回 400，得到 %d", rr.Code)
+	}
+}
@@ -0,0 +1,64 @@
+package sseserver
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// maxPooledFrameSize 超过该容量的缓冲不放回池中，避免个别大消息长期占用内存
+const maxPooledFrameSize = 64 << 10
+
+// 合并写出时单次最多包含的帧数与字节数
+const (
+	maxBatchFrames = 64
+	maxBatchSize   = 32 << 10
+)
+
+var (
+	framePool = sync.Pool{New: func() any {
+		return &frame{buf: make([]byte, 0, 512), pooled: true}
+	}}
+	batchPool = sync.Pool{New: func() any {
+		b := make([]byte, 0, 4096)
+		return &b
+	}}
+)
+
+// frame 是编码后的一帧数据。广播时所有连接共享同一个 frame，每放入一个连接的发送缓冲持有一次引用，
+// 连接写出或丢弃后释放；引用归零时缓冲归还到池中复用。
+type frame struct {
+	buf    []byte
+	msg    *SSEMessage // 编码来源的消息，被挤出发送缓冲时传给 OnMessageDropped；newFrame 包装的数据为 nil
+	refs   int32
+	pooled bool // 为 false 时不计引用，由 GC 回收
+}
+
+// encodeFrame 从池中取出缓冲编码消息，调用方持有一次引用
+func encodeFrame(msg *SSEMessage) *frame {
+	f := framePool.Get().(*frame)
+	if size := msg.frameSize(); cap(f.buf) < size {
+		f.buf = make([]byte, 0, size)
+	}
+	f.buf = msg.appendFrame(f.buf[:0])
+	f.msg = msg
+	f.refs = 1
+	return f
+}
+
+// newFrame 包装不来自池的数据
+func newFrame(data []byte) *frame {
+	return &frame{buf: data}
+}
+
+func (f *frame) retain() {
+	if f.pooled {
+		atomic.AddInt32(&f.refs, 1)
+	}
+}
+
+func (f *frame) release() {
+	if f.pooled && atomic.AddInt32(&f.refs, -1) == 0 && cap(f.buf) <= maxPooledFrameSize {
+		f.msg = nil
+		framePool.Put(f)
+	}
+}
@@ -0,0 +1,80 @@
+package sseserver
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBroadcastSharesFrame(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+	var conns []*connection
+	for i := 0; i < 3; i++ {
+		conn := h.newConnection()
+		h.registerConnection(conn)
+		conns = append(conns, conn)
+	}
+
+	publishSync(t, h, SSEMessage{Data: []byte("shared")})
+	var frames []*frame
+	for _, conn := range conns {
+		frames = append(frames, <-conn.send)
+	}
+	f := frames[0]
+	if frames[1] != f || frames[2] != f {
+		t.Fatalf("所有连接应共享同一个帧")
+	}
+	if f.refs != 3 {
+		t.Fatalf("引用计数错误，得到 %d，想要 3", f.refs)
+	}
+	for _, f := range frames {
+		f.release()
+	}
+	if f.refs != 0 {
+		t.Errorf("释放后引用计数应归零，得到 %d", f.refs)
+	}
+}
+
+// countingWriter 统计 Write 与 Flush 的调用次数
+type countingWriter struct {
+	*httptest.ResponseRecorder
+	writes, flushes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.ResponseRecorder.Write(p)
+}
+
+func (w *countingWriter) Flush() {
+	w.flushes++
+}
+
+func TestWriteBatch(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	queue := make(chan *frame, 4)
+	msgs := []SSEMessage{{Data: []byte("1")}, {Data: []byte("2")}, {Data: []byte("3")}}
+	var frames []*frame
+	for i := range msgs {
+		frames = append(frames, encodeFrame(&msgs[i]))
+	}
+	queue <- frames[1]
+	queue <- frames[2]
+
+	w := &countingWriter{ResponseRecorder: httptest.NewRecorder()}
+	if err := server.writeBatch(w, w, frames[0], queue); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes != 1 || w.flushes != 1 {
+		t.Errorf("排队的帧应合并为一次写出，得到 %d 次 Write、%d 次 Flush", w.writes, w.flushes)
+	}
+	if got := w.Body.String(); got != "data:1\n\ndata:2\n\ndata:3\n\n" {
+		t.Errorf("合并写出的内容错误，得到 %q", got)
+	}
+	if len(queue) != 0 {
+		t.Errorf("发送缓冲应已清空")
+	}
+}
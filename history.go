@@ -0,0 +1,144 @@
+package sseserver
+
+import (
+	"sort"
+	"sync"
+)
+
+type historyEntry struct {
+	seq uint64 // 全局递增序号，用于跨 namespace 合并时保持发送顺序
+	msg SSEMessage
+}
+
+// historyRing 固定容量的环形缓冲，写满后覆盖最旧的消息
+type historyRing struct {
+	buf  []historyEntry
+	head int // 最旧元素的位置
+	n    int
+}
+
+func newHistoryRing(capacity int) *historyRing {
+	return &historyRing{buf: make([]historyEntry, capacity)}
+}
+
+func (r *historyRing) push(e historyEntry) {
+	if r.n < len(r.buf) {
+		r.buf[(r.head+r.n)%len(r.buf)] = e
+		r.n++
+		return
+	}
+	r.buf[r.head] = e
+	r.head = (r.head + 1) % len(r.buf)
+}
+
+func (r *historyRing) each(fn func(e historyEntry)) {
+	for i := 0; i < r.n; i++ {
+		fn(r.buf[(r.head+i)%len(r.buf)])
+	}
+}
+
+// history 是 MessageStore 的内存实现，按 namespace 保存最近的消息，进程重启后丢失
+type history struct {
+	mu    sync.Mutex
+	size  int
+	seq   uint64
+	rings map[string]*historyRing
+}
+
+// NewMemoryStore 创建内存消息存储，每个 namespace 最多保留 size 条消息
+func NewMemoryStore(size int) MessageStore {
+	return newHistory(size)
+}
+
+func newHistory(size int) *history {
+	return &history{
+		size:  size,
+		rings: make(map[string]*historyRing),
+	}
+}
+
+// Append 实现 MessageStore
+func (hs *history) Append(msg SSEMessage) error {
+	hs.append(msg)
+	return nil
+}
+
+// ReadSince 实现 MessageStore
+func (hs *history) ReadSince(patterns []string, id, until string) ([]SSEMessage, error) {
+	return hs.since(patterns, id, until), nil
+}
+
+// Trim 实现 MessageStore，环形缓冲写满时已自动覆盖最旧的消息，无需额外清理
+func (hs *history) Trim() error {
+	return nil
+}
+
+func (hs *history) append(msg SSEMessage) {
+	ns := normalizeNamespace(msg.Namespace)
+	hs.mu.Lock()
+	ring := hs.rings[ns]
+	if ring == nil {
+		ring = newHistoryRing(hs.size)
+		hs.rings[ns] = ring
+	}
+	hs.seq++
+	ring.push(historyEntry{seq: hs.seq, msg: msg})
+	hs.mu.Unlock()
+}
+
+// since 返回订阅 patterns 的连接在 lastEventID 之后、until（含）之前错过的消息，按发送顺序排列。
+// lastEventID 不在历史中（已被覆盖或来自其它实例）时从最早保留的消息开始；
+// until 为空时不设上限，不在历史中时返回空。
+func (hs *history) since(patterns []string, lastEventID, until string) []SSEMessage {
+	hs.mu.Lock()
+	// 两个 ID 都可能属于其它 namespace，需在全部历史中定位其序号
+	var after, last uint64
+	for _, ring := range hs.rings {
+		ring.each(func(e historyEntry) {
+			if e.msg.ID == lastEventID && e.seq > after {
+				after = e.seq
+			}
+			if until != "" && e.msg.ID == until && e.seq > last {
+				last = e.seq
+			}
+		})
+	}
+	if until == "" {
+		last = hs.seq
+	}
+
+	var entries []historyEntry
+	collect := func(ring *historyRing) {
+		if ring != nil {
+			ring.each(func(e historyEntry) {
+				if e.seq > after && e.seq <= last {
+					entries = append(entries, e)
+				}
+			})
+		}
+	}
+	for ns, ring := range hs.rings {
+		if ns == "" || matchesAny(patterns, ns) {
+			collect(ring)
+		}
+	}
+	hs.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+	msgs := make([]SSEMessage, len(entries))
+	for i, e := range entries {
+		msgs[i] = e.msg
+	}
+	return msgs
+}
+
+func matchesAny(patterns []string, ns string) bool {
+	for _, pattern := range patterns {
+		if namespaceMatches(pattern, ns) {
+			return true
+		}
+	}
+	return false
+}
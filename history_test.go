@@ -0,0 +1,55 @@
+package sseserver
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHistoryRingOverwrite(t *testing.T) {
+	hs := newHistory(3)
+	for i := 1; i <= 5; i++ {
+		hs.append(SSEMessage{ID: strconv.Itoa(i), Data: []byte("x")})
+	}
+
+	msgs := hs.since([]string{""}, "unknown", "")
+	if len(msgs) != 3 {
+		t.Fatalf("历史消息数量错误，得到 %d，想要 3", len(msgs))
+	}
+	for i, want := range []string{"3", "4", "5"} {
+		if msgs[i].ID != want {
+			t.Errorf("第 %d 条历史消息 ID 错误，得到 %s，想要 %s", i, msgs[i].ID, want)
+		}
+	}
+}
+
+func TestHistorySince(t *testing.T) {
+	hs := newHistory(10)
+	hs.append(SSEMessage{ID: "1", Data: []byte("a"), Namespace: "/a"})
+	hs.append(SSEMessage{ID: "2", Data: []byte("b"), Namespace: "/b"})
+	hs.append(SSEMessage{ID: "3", Data: []byte("global")})
+	hs.append(SSEMessage{ID: "4", Data: []byte("a"), Namespace: "/a"})
+
+	ids := func(msgs []SSEMessage) string {
+		s := ""
+		for _, m := range msgs {
+			s += m.ID
+		}
+		return s
+	}
+
+	if got := ids(hs.since([]string{"/a"}, "1", "")); got != "34" {
+		t.Errorf("/a 补发错误，得到 %s，想要 34", got)
+	}
+	if got := ids(hs.since([]string{""}, "2", "")); got != "34" {
+		t.Errorf("根订阅补发错误，得到 %s，想要 34", got)
+	}
+	if got := ids(hs.since([]string{"/b"}, "4", "")); got != "" {
+		t.Errorf("/b 不应补发消息，得到 %s", got)
+	}
+	if got := ids(hs.since([]string{"/a", "/b"}, "", "3")); got != "123" {
+		t.Errorf("读到 until 为止错误，得到 %s，想要 123", got)
+	}
+	if got := ids(hs.since([]string{""}, "1", "unknown")); got != "" {
+		t.Errorf("until 不在历史中时不应补发，得到 %s", got)
+	}
+}
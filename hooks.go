@@ -0,0 +1,92 @@
+package sseserver
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DisconnectReason 是连接断开的原因，传给 ServerOptions.OnDisconnect
+type DisconnectReason int
+
+const (
+	// DisconnectClientClosed 客户端关闭连接，或写入失败
+	DisconnectClientClosed DisconnectReason = iota
+	// DisconnectSlowConsumer 发送缓冲已满，按慢消费者策略断开
+	DisconnectSlowConsumer
+	// DisconnectIdle 超过 ConnectionTimeout 没有活动，被定期清理
+	DisconnectIdle
+	// DisconnectServerStop 服务停止
+	DisconnectServerStop
+	// DisconnectLimitExceeded 被准入控制拒绝，连接没有建立
+	DisconnectLimitExceeded
+	// DisconnectKicked 通过 Server.Disconnect、DisconnectIP 或管理接口主动断开
+	DisconnectKicked
+)
+
+var disconnectReasonNames = [...]string{"client_closed", "slow_consumer", "idle", "server_stop", "limit_exceeded", "kicked"}
+
+func (r DisconnectReason) String() string {
+	if r >= 0 && int(r) < len(disconnectReasonNames) {
+		return disconnectReasonNames[r]
+	}
+	return "unknown"
+}
+
+// setCloseReason 记录连接的断开原因，只有第一次记录生效：
+// hub 主动断开时先记录原因再关闭 send，连接处理函数随后退出时不会覆盖
+func (c *connection) setCloseReason(r DisconnectReason) {
+	atomic.CompareAndSwapInt32(&c.closeReason, 0, int32(r)+1)
+}
+
+func (c *connection) disconnectReason() DisconnectReason {
+	if r := atomic.LoadInt32(&c.closeReason); r > 0 {
+		return DisconnectReason(r - 1)
+	}
+	return DisconnectClientClosed
+}
+
+// notifyConnect 在连接注册后调用 OnConnect，send 只向该连接推送
+func (s *Server) notifyConnect(conn *connection) {
+	if s.Options.OnConnect == nil {
+		return
+	}
+	id := conn.id
+	sh := s.hub.shardFor(id)
+	sh.mu.RLock()
+	info := conn.info()
+	sh.mu.RUnlock()
+	s.Options.OnConnect(info, func(msg SSEMessage) error {
+		return s.hub.sendToConnection(id, msg)
+	})
+}
+
+// notifyRejected 以 DisconnectLimitExceeded 调用 OnDisconnect，此时连接尚未创建，ID 为空
+func (s *Server) notifyRejected(ip, userID string, namespaces []string) {
+	if s.Options.OnDisconnect == nil {
+		return
+	}
+	now := time.Now()
+	s.Options.OnDisconnect(ConnectionInfo{
+		RemoteIP:     ip,
+		Namespaces:   namespaces,
+		UserID:       userID,
+		CreatedAt:    now,
+		LastActivity: now,
+	}, DisconnectLimitExceeded)
+}
+
+// messageDropped 在消息因队列或发送缓冲已满被丢弃时调用 OnMessageDropped，conn 为 nil 表示广播队列已满
+func (h *hub) messageDropped(conn *connection, msg *SSEMessage) {
+	if h.onMessageDropped == nil {
+		return
+	}
+	if conn == nil {
+		h.onMessageDropped(nil, *msg)
+		return
+	}
+	sh := h.shardFor(conn.id)
+	sh.mu.RLock()
+	info := conn.info()
+	sh.mu.RUnlock()
+	h.onMessageDropped(&info, *msg)
+}
@@ -0,0 +1,178 @@
+package sseserver
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type disconnectEvent struct {
+	info   ConnectionInfo
+	reason DisconnectReason
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	disconnects := make(chan disconnectEvent, 8)
+	server := NewServer(ServerOptions{
+		MaxConnections: 1,
+		OnConnect: func(info ConnectionInfo, send func(SSEMessage) error) {
+			if err := send(SSEMessage{Event: "snapshot", Data: []byte(info.ID)}); err != nil {
+				t.Errorf("推送初始快照失败: %v", err)
+			}
+		},
+		OnDisconnect: func(info ConnectionInfo, reason DisconnectReason) {
+			disconnects <- disconnectEvent{info, reason}
+		},
+	})
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/subscribe/room", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	id := resp.Header.Get("X-SSE-Connection-ID")
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取响应失败: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	if got := strings.Join(lines, ""); got != "event:snapshot\ndata:"+id+"\n\n" {
+		t.Errorf("OnConnect 推送的快照错误，得到 %q", got)
+	}
+
+	// 超过连接上限的请求以 DisconnectLimitExceeded 通知
+	rejected, err := http.Get(ts.URL + "/subscribe/room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected.Body.Close()
+	select {
+	case ev := <-disconnects:
+		if ev.reason != DisconnectLimitExceeded || ev.info.ID != "" || ev.info.Namespaces[0] != "/room" {
+			t.Errorf("准入拒绝的通知错误: %+v %v", ev.info, ev.reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("准入拒绝未调用 OnDisconnect")
+	}
+
+	cancel()
+	select {
+	case ev := <-disconnects:
+		if ev.reason != DisconnectClientClosed || ev.info.ID != id {
+			t.Errorf("客户端断开的通知错误: %s %v", ev.info.ID, ev.reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("客户端断开未调用 OnDisconnect")
+	}
+}
+
+func TestDisconnectReasons(t *testing.T) {
+	disconnects := make(chan disconnectEvent, 8)
+	server := NewServer(ServerOptions{
+		SendBufferSize: 1,
+		OnDisconnect: func(info ConnectionInfo, reason DisconnectReason) {
+			disconnects <- disconnectEvent{info, reason}
+		},
+	})
+	h := server.hub
+
+	expect := func(conn *connection, want DisconnectReason) {
+		t.Helper()
+		select {
+		case ev := <-disconnects:
+			if ev.info.ID != conn.id || ev.reason != want {
+				t.Errorf("断开原因错误，得到 %v，想要 %v", ev.reason, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v 未调用 OnDisconnect", want)
+		}
+	}
+	register := func() *connection {
+		conn := h.newConnection()
+		h.registerConnection(conn)
+		return conn
+	}
+
+	slow := register()
+	publishSync(t, h, SSEMessage{Data: []byte("1")})
+	publishSync(t, h, SSEMessage{Data: []byte("2")})
+	expect(slow, DisconnectSlowConsumer)
+
+	kicked := register()
+	server.Disconnect(kicked.id)
+	expect(kicked, DisconnectKicked)
+
+	idle := register()
+	idle.lastActivity = time.Now().Add(-2 * ConnectionTimeout)
+	h.cleanupExpiredConnections()
+	expect(idle, DisconnectIdle)
+
+	stopped := register()
+	server.Stop()
+	expect(stopped, DisconnectServerStop)
+
+	if DisconnectSlowConsumer.String() != "slow_consumer" || DisconnectReason(99).String() != "unknown" {
+		t.Errorf("DisconnectReason.String 错误")
+	}
+}
+
+func TestOnMessageDropped(t *testing.T) {
+	type dropEvent struct {
+		conn *ConnectionInfo
+		data string
+	}
+	drops := make(chan dropEvent, 8)
+	server := NewServer(ServerOptions{
+		SendBufferSize:     1,
+		SlowConsumerPolicy: SlowConsumerDropNewest,
+		OnMessageDropped: func(conn *ConnectionInfo, msg SSEMessage) {
+			drops <- dropEvent{conn, string(msg.Data)}
+		},
+	})
+	defer server.Stop()
+	h := server.hub
+
+	conn := h.newConnection()
+	h.registerConnection(conn)
+	publishSync(t, h, SSEMessage{Data: []byte("kept")})
+	publishSync(t, h, SSEMessage{Data: []byte("dropped")})
+	if ev := <-drops; ev.conn == nil || ev.conn.ID != conn.id || ev.data != "dropped" {
+		t.Errorf("发送缓冲溢出的通知错误: %+v", ev)
+	}
+
+	// DropOldest 下新消息放入缓冲，通知的是被挤出的旧消息，新消息计入 Accepted
+	h.slowConsumerPolicy = SlowConsumerDropOldest
+	result := publishSync(t, h, SSEMessage{Data: []byte("newest")})
+	if result.Accepted != 1 || result.Dropped != 0 {
+		t.Errorf("挤出旧消息时投递结果错误: %+v", result)
+	}
+	if ev := <-drops; ev.conn == nil || ev.data != "kept" {
+		t.Errorf("应通知被挤出的消息，得到 %+v", ev)
+	}
+
+	// 未启动 worker 的 hub，用于构造队列积压
+	idle := newHub()
+	idle.onMessageDropped = h.onMessageDropped
+	queue := idle.queueFor(&SSEMessage{})
+	for len(queue) < cap(queue) {
+		queue <- broadcastJob{}
+	}
+	idle.enqueue(broadcastJob{msg: SSEMessage{Data: []byte("queue full")}})
+	if ev := <-drops; ev.conn != nil || ev.data != "queue full" {
+		t.Errorf("广播队列已满的通知错误: %+v", ev)
+	}
+}
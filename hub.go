@@ -1,48 +1,73 @@
 package sseserver
 
 import (
-	"log"
+	"context"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 const (
-	MaxConnections          = 10000
-	defaultBroadcastWorkers = 4
+	MaxConnections           = 10000 // 默认的全局最大连接数，见 ServerOptions.MaxConnections
+	defaultBroadcastWorkers  = 4
+	broadcastQueueCapacity   = 2048 // 全部分发队列的总容量，按 worker 数平分
+	defaultMaxReplayMessages = 1000
 )
 
+// broadcastJob 是广播队列中的一项，result 非空时 worker 投递完成后回传投递结果
+type broadcastJob struct {
+	msg    SSEMessage
+	result chan PublishResult
+}
+
 type hub struct {
-	connections      map[*connection]bool
-	broadcast        chan SSEMessage
-	broadcastQueue   chan SSEMessage
-	register         chan *connection
-	unregister       chan *connection
-	stopChan         chan struct{}
-	debug            bool
+	shards          []*hubShard
+	presenceMu      sync.Mutex
+	presence        map[string]map[string]*PresenceMember
+	presenceEvents  bool // 成员加入或离开 namespace 时广播 join/leave 事件
+	broadcast       chan SSEMessage
+	broadcastQueues []chan broadcastJob // 按排序键分区，每个分发 worker 独占一个队列
+	unregister      chan *connection
+	stopChan        chan struct{}
+	logger          Logger
+
+	onDisconnect     func(info ConnectionInfo, reason DisconnectReason)
+	onMessageDropped func(conn *ConnectionInfo, msg SSEMessage)
 	activeCount      int32
-	broadcastWorkers int
 	droppedMessages  int64
-	pool             *sync.Pool
-	closeOnce        sync.Once
-	connMu           sync.RWMutex
-	slicePool        *sync.Pool
+	nextID           uint64
+	avgFrameSize     uint64       // 广播消息大小的指数移动平均，见 observeFrameSize
+	frameSizes       sync.Map     // namespace → *uint64，各 namespace 消息大小的指数移动平均
+	frameSizeCount   int32        // frameSizes 中的 namespace 数
+	store            MessageStore // 为 nil 时不保留历史消息
+	storeMu          sync.Mutex   // 保证写入存储的顺序与 storeSeq 一致
+	storeSeq         uint64
+	storeLastID      string // 存储中最后一条消息的 ID，与 storeSeq 一同更新
+	maxReplay        int    // 单次补发的最大消息数，0 表示不限制
+	metrics          *metrics
+
+	sendBufferSize      int
+	slowConsumerPolicy  SlowConsumerPolicy
+	slowConsumerTimeout time.Duration
+	closeOnce           sync.Once
+	slicePool           *sync.Pool
 }
 
 func newHub() *hub {
 	return &hub{
-		connections:      make(map[*connection]bool),
-		broadcast:        make(chan SSEMessage, 1024),
-		broadcastQueue:   make(chan SSEMessage, 2048),
-		register:         make(chan *connection, 8192),
-		unregister:       make(chan *connection, 8192),
-		stopChan:         make(chan struct{}),
-		broadcastWorkers: defaultBroadcastWorkers,
-		pool: &sync.Pool{
-			New: func() any {
-				return &connection{}
-			},
-		},
+		shards:          newHubShards(defaultHubShards),
+		presence:        make(map[string]map[string]*PresenceMember),
+		broadcast:       make(chan SSEMessage, 1024),
+		broadcastQueues: newBroadcastQueues(defaultBroadcastWorkers),
+		unregister:      make(chan *connection, 8192),
+		stopChan:        make(chan struct{}),
+		metrics:         newMetrics(),
+		logger:          leveledLogger{Logger: NewStdLogger(nil), debug: new(int32)},
+
+		sendBufferSize:      defaultSendBufferSize,
+		slowConsumerTimeout: defaultSlowConsumerTimeout,
+		maxReplay:           defaultMaxReplayMessages,
 		slicePool: &sync.Pool{
 			New: func() any {
 				s := make([]*connection, 0, 128)
@@ -52,11 +77,13 @@ func newHub() *hub {
 	}
 }
 
-func (h *hub) Start(debug bool) {
-	h.debug = debug
+func (h *hub) Start() {
 	go h.run()
-	for i := 0; i < h.broadcastWorkers; i++ {
-		go h.broadcastWorker()
+	for _, queue := range h.broadcastQueues {
+		go h.broadcastWorker(queue)
+	}
+	for _, sh := range h.shards {
+		go h.shardWorker(sh)
 	}
 	go h.periodicLog()
 	h.startCleanupRoutine()
@@ -65,24 +92,18 @@ func (h *hub) Start(debug bool) {
 func (h *hub) run() {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Println("Recovered in hub.run", r)
+			h.logger.Error("recovered in hub.run", "panic", r)
 		}
 	}()
 
 	for {
 		select {
-		case conn := <-h.register:
-			h.registerConnection(conn)
 		case conn := <-h.unregister:
 			h.unregisterConnection(conn)
 		case message := <-h.broadcast:
-			select {
-			case h.broadcastQueue <- message:
-			default:
-				atomic.AddInt64(&h.droppedMessages, 1)
-				if h.debug {
-					log.Println("broadcast queue full, message dropped")
-				}
+			h.assignID(&message)
+			if !h.enqueue(broadcastJob{msg: message}) {
+				h.logger.Debug("broadcast queue full, message dropped", "namespace", message.Namespace)
 			}
 		case <-h.stopChan:
 			h.closeAllConnections()
@@ -91,104 +112,276 @@ func (h *hub) run() {
 	}
 }
 
-func (h *hub) broadcastWorker() {
+func (h *hub) broadcastWorker(queue chan broadcastJob) {
 	for {
 		select {
-		case msg, ok := <-h.broadcastQueue:
+		case job, ok := <-queue:
 			if !ok {
 				return
 			}
-			h.broadcastMessage(msg)
+			h.dispatch(job)
 		case <-h.stopChan:
 			return
 		}
 	}
 }
 
-func (h *hub) registerConnection(conn *connection) {
-	if atomic.LoadInt32(&h.activeCount) >= MaxConnections {
-		if h.debug {
-			log.Println("达到最大连接数限制，拒绝新连接")
-		}
-		conn.safeClose()
-		h.putConnection(conn)
-		return
+// registerConnection 将连接加入分片，hub 已停止时不注册并返回 false。
+// 停止检查在分片锁内进行：检查通过的连接必然在 closeAllConnections 读取该分片之前加入，会随 hub 一起关闭。
+func (h *hub) registerConnection(conn *connection) bool {
+	replay := h.store != nil && conn.lastEventID != ""
+	if replay {
+		// 补发完成前投递的帧暂存在连接上，保证历史消息先于实时消息到达
+		conn.replaying = true
+	}
+	var namespaces []string
+	var lastID string
+	sh := h.shardFor(conn.id)
+	sh.mu.Lock()
+	select {
+	case <-h.stopChan:
+		sh.mu.Unlock()
+		conn.replaying = false
+		return false
+	default:
+	}
+	sh.add(conn)
+	h.presenceJoin(conn, conn.namespaces)
+	// 连接加入分片时记下存储序号：序号之内的消息由补发送达，之后写入的消息投递时必然能看到该连接，
+	// 因此既不会重复也不会遗漏。读取存储在锁外进行，不阻塞投递与写入。
+	if replay {
+		namespaces = conn.namespaces
+		h.storeMu.Lock()
+		conn.replayedSeq = h.storeSeq
+		lastID = h.storeLastID
+		h.storeMu.Unlock()
 	}
-	h.connMu.Lock()
-	h.connections[conn] = true
-	h.connMu.Unlock()
+	sh.mu.Unlock()
 	newCount := atomic.AddInt32(&h.activeCount, 1)
-	if h.debug {
-		log.Printf("新连接注册，当前活跃连接数: %d\n", newCount)
+	atomic.AddUint64(&h.metrics.registered, 1)
+	h.logger.Debug("connection registered", "conn", conn.id, "ip", conn.remoteIP, "active", newCount)
+	if replay {
+		conn.finishReplay(h.replayData(conn, namespaces, lastID))
 	}
+	return true
 }
 
 func (h *hub) unregisterConnection(conn *connection) {
-	h.connMu.Lock()
-	_, ok := h.connections[conn]
-	if ok {
-		delete(h.connections, conn)
+	sh := h.shardFor(conn.id)
+	sh.mu.Lock()
+	ok := sh.remove(conn)
+	var info ConnectionInfo
+	if ok && h.onDisconnect != nil {
+		info = conn.info()
 	}
-	h.connMu.Unlock()
+	sh.mu.Unlock()
 
 	if ok {
-		conn.safeClose()
-		h.putConnection(conn)
+		h.presenceLeave(conn, conn.namespaces)
 		newCount := atomic.AddInt32(&h.activeCount, -1)
-		if h.debug {
-			log.Printf("Connection unregistered, active connections: %d", newCount)
+		atomic.AddUint64(&h.metrics.unregistered, 1)
+		h.logger.Debug("connection unregistered", "conn", conn.id, "ip", conn.remoteIP, "active", newCount)
+		conn.safeClose()
+		if h.onDisconnect != nil {
+			h.onDisconnect(info, conn.disconnectReason())
 		}
 	}
 }
 
-func (h *hub) broadcastMessage(message SSEMessage) {
-	data := message.Bytes()
+// dispatch 将广播分发到各分片的队列，由分片 worker 并行投递
+func (h *hub) dispatch(bj broadcastJob) {
+	job := h.newShardJob(bj.msg, bj.result)
+	job.pending = len(h.shards)
+	for _, sh := range h.shards {
+		select {
+		case sh.queue <- job:
+		case <-h.stopChan:
+			return
+		}
+	}
+}
 
-	connsPtr := h.slicePool.Get().(*[]*connection)
-	conns := (*connsPtr)[:0]
+// newShardJob 编码消息并写入消息存储。所有连接共享同一个编码后的帧。
+func (h *hub) newShardJob(message SSEMessage, done chan PublishResult) *shardJob {
+	job := &shardJob{
+		msg:     message,
+		frame:   encodeFrame(&message),
+		payload: lazyJSON{raw: message.Data},
+		done:    done,
+	}
+	atomic.AddUint64(&h.metrics.messagesBroadcast, 1)
+	h.observeFrameSize(normalizeNamespace(message.Namespace), len(job.frame.buf))
+	if h.store != nil {
+		h.storeMu.Lock()
+		if err := h.store.Append(message); err != nil {
+			h.logger.Error("message store append failed", "id", message.ID, "namespace", message.Namespace, "err", err)
+		} else {
+			h.storeLastID = message.ID
+		}
+		h.storeSeq++
+		job.seq = h.storeSeq
+		h.storeMu.Unlock()
+	}
+	return job
+}
 
-	h.connMu.RLock()
-	for conn := range h.connections {
-		conns = append(conns, conn)
+// publish 绕过 broadcast 通道直接放入广播队列并等待投递结果，队列已满时立即返回 ErrQueueFull
+func (h *hub) publish(ctx context.Context, message SSEMessage) (PublishResult, error) {
+	h.assignID(&message)
+	job := broadcastJob{msg: message, result: make(chan PublishResult, 1)}
+	select {
+	case <-h.stopChan:
+		return PublishResult{}, ErrServerStopped
+	default:
+	}
+	if !h.enqueue(job) {
+		return PublishResult{}, ErrQueueFull
 	}
-	h.connMu.RUnlock()
+	select {
+	case result := <-job.result:
+		return result, nil
+	case <-ctx.Done():
+		return PublishResult{}, ctx.Err()
+	case <-h.stopChan:
+		return PublishResult{}, ErrServerStopped
+	}
+}
 
-	var failedConns []*connection
+// deliver 按慢消费者策略向连接投递一帧，记录发送缓冲溢出的次数，并通知实际被丢弃的消息：
+// 新消息被丢弃时为 msg，新消息挤出缓冲中更早的消息时为被挤出的消息
+func (h *hub) deliver(conn *connection, f *frame, msg *SSEMessage) deliveryResult {
+	result, evicted := conn.deliver(f, h.slowConsumerPolicy, h.slowConsumerTimeout)
+	switch result {
+	case deliveryDropped, deliveryFailed:
+		atomic.AddUint64(&h.metrics.sendBufferDrops, 1)
+		h.messageDropped(conn, msg)
+	case deliveryEvicted:
+		atomic.AddUint64(&h.metrics.sendBufferDrops, 1)
+		if evicted != nil {
+			h.messageDropped(conn, evicted)
+		}
+	}
+	return result
+}
+
+// dropConnections 以 reason 注销连接，unregister 队列满时直接注销
+func (h *hub) dropConnections(conns []*connection, reason DisconnectReason) {
 	for _, conn := range conns {
-		if conn.isClosed() {
-			continue
+		conn.setCloseReason(reason)
+		select {
+		case h.unregister <- conn:
+		default:
+			h.unregisterConnection(conn)
 		}
-		if !conn.trySend(data) {
-			failedConns = append(failedConns, conn)
+	}
+}
+
+// sendToConnection 向指定 ID 的连接发送消息
+func (h *hub) sendToConnection(id string, message SSEMessage) error {
+	conn := h.connectionByID(id)
+	if conn == nil {
+		return ErrConnectionNotFound
+	}
+	f := encodeFrame(&message)
+	defer f.release()
+	switch h.deliver(conn, f, &message) {
+	case deliveryOK, deliveryEvicted:
+		return nil
+	case deliveryFailed:
+		h.dropConnections([]*connection{conn}, DisconnectSlowConsumer)
+		return ErrSendBufferFull
+	case deliveryDropped:
+		return ErrSendBufferFull
+	default:
+		return ErrConnectionNotFound
+	}
+}
+
+// sendToUser 向用户的所有连接发送消息，返回成功送达的连接数
+func (h *hub) sendToUser(userID string, message SSEMessage) int {
+	connsPtr := h.slicePool.Get().(*[]*connection)
+	conns := (*connsPtr)[:0]
+
+	for _, sh := range h.shards {
+		sh.mu.RLock()
+		for conn := range sh.byUser[userID] {
+			conns = append(conns, conn)
+		}
+		sh.mu.RUnlock()
+	}
+
+	var sent, failed int
+	if len(conns) > 0 {
+		f := encodeFrame(&message)
+		for _, conn := range conns {
+			switch h.deliver(conn, f, &message) {
+			case deliveryOK, deliveryEvicted:
+				sent++
+			case deliveryFailed:
+				conns[failed] = conn
+				failed++
+			}
 		}
+		f.release()
 	}
 
+	h.dropConnections(conns[:failed], DisconnectSlowConsumer)
 	for i := range conns {
 		conns[i] = nil
 	}
 	*connsPtr = conns[:0]
 	h.slicePool.Put(connsPtr)
+	return sent
+}
 
-	for _, conn := range failedConns {
-		select {
-		case h.unregister <- conn:
-		default:
-			h.unregisterConnection(conn)
+// assignID 为未指定 ID 的消息分配单调递增的 ID，仅在启用历史消息时生效
+func (h *hub) assignID(msg *SSEMessage) {
+	if h.store == nil || msg.ID != "" {
+		return
+	}
+	msg.ID = strconv.FormatUint(atomic.AddUint64(&h.nextID, 1), 10)
+}
+
+// replayData 读取连接错过的历史消息并合并为一帧，避免占满 send 缓冲。
+// 存储按连接订阅的 namespace 过滤，只读到 lastID（连接注册时存储中的最后一条）为止，
+// 之后的消息由实时投递送达；超过 maxReplay 条时只补发最近的部分。
+func (h *hub) replayData(conn *connection, namespaces []string, lastID string) []byte {
+	if lastID == "" {
+		return nil
+	}
+	msgs, err := h.store.ReadSince(namespaces, conn.lastEventID, lastID)
+	if err != nil {
+		h.logger.Error("message store read failed", "conn", conn.id, "last_event_id", conn.lastEventID, "err", err)
+		return nil
+	}
+
+	out := msgs[:0]
+	for i := range msgs {
+		if conn.filter.match(&msgs[i], &lazyJSON{raw: msgs[i].Data}) {
+			out = append(out, msgs[i])
 		}
 	}
+	if h.maxReplay > 0 && len(out) > h.maxReplay {
+		h.logger.Warn("replay truncated", "conn", conn.id, "last_event_id", conn.lastEventID, "missed", len(out), "replayed", h.maxReplay)
+		out = out[len(out)-h.maxReplay:]
+	}
+	var data []byte
+	for i := range out {
+		data = append(data, out[i].Bytes()...)
+	}
+	return data
 }
 
 func (h *hub) closeAllConnections() {
 	connsPtr := h.slicePool.Get().(*[]*connection)
 	conns := (*connsPtr)[:0]
 
-	h.connMu.Lock()
-	for conn := range h.connections {
+	h.eachShardConnection(func(conn *connection) {
 		conns = append(conns, conn)
-	}
-	h.connMu.Unlock()
+	})
 
 	for _, conn := range conns {
+		conn.setCloseReason(DisconnectServerStop)
 		h.unregisterConnection(conn)
 	}
 
@@ -205,20 +398,18 @@ func (h *hub) Stop() {
 	})
 }
 
+// newConnection 创建新连接。连接不做池化复用：分片 worker、连接处理函数与回调在注销后
+// 仍可能持有连接指针，复用会让它们读到或写入另一个连接的状态
 func (h *hub) newConnection() *connection {
-	conn := h.pool.Get().(*connection)
-	conn.reset()
-	conn.hub = h
-	conn.send = make(chan []byte, 256)
 	now := time.Now()
-	conn.createdAt = now
-	conn.lastActivity = now
-	return conn
-}
-
-func (h *hub) putConnection(conn *connection) {
-	conn.hub = nil
-	h.pool.Put(conn)
+	return &connection{
+		hub:          h,
+		id:           newConnectionID(),
+		namespaces:   []string{""},
+		send:         make(chan *frame, h.sendBufferSize),
+		createdAt:    now,
+		lastActivity: now,
+	}
 }
 
 func (h *hub) periodicLog() {
@@ -227,9 +418,7 @@ func (h *hub) periodicLog() {
 	for {
 		select {
 		case <-ticker.C:
-			if h.debug {
-				log.Printf("当前活跃连接数: %d", atomic.LoadInt32(&h.activeCount))
-			}
+			h.logger.Debug("active connections", "active", atomic.LoadInt32(&h.activeCount))
 		case <-h.stopChan:
 			return
 		}
@@ -240,15 +429,14 @@ func (h *hub) cleanupExpiredConnections() {
 	connsPtr := h.slicePool.Get().(*[]*connection)
 	expiredConns := (*connsPtr)[:0]
 
-	h.connMu.RLock()
-	for conn := range h.connections {
+	h.eachShardConnection(func(conn *connection) {
 		if conn.isExpired() {
 			expiredConns = append(expiredConns, conn)
 		}
-	}
-	h.connMu.RUnlock()
+	})
 
 	for _, conn := range expiredConns {
+		conn.setCloseReason(DisconnectIdle)
 		select {
 		case h.unregister <- conn:
 		case <-h.stopChan:
@@ -278,6 +466,11 @@ func (h *hub) startCleanupRoutine() {
 			select {
 			case <-ticker.C:
 				h.cleanupExpiredConnections()
+				if h.store != nil {
+					if err := h.store.Trim(); err != nil {
+						h.logger.Error("message store trim failed", "err", err)
+					}
+				}
 			case <-h.stopChan:
 				return
 			}
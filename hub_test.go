@@ -1,25 +1,38 @@
 package sseserver
 
 import (
+	"context"
 	"sync/atomic"
 	"testing"
 	"time"
 )
 
+// publishSync 经广播队列与分片 worker 投递一条消息，等待投递完成并返回结果
+func publishSync(tb testing.TB, h *hub, msg SSEMessage) PublishResult {
+	tb.Helper()
+	result, err := h.publish(context.Background(), msg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return result
+}
+
 func TestNewHub(t *testing.T) {
 	h := newHub()
 	if h == nil {
 		t.Fatal("newHub() 返回了 nil")
 	}
-	if h.connections == nil {
-		t.Error("connections map 未初始化")
+	if len(h.shards) != defaultHubShards {
+		t.Errorf("分片数量错误，得到 %d，想要 %d", len(h.shards), defaultHubShards)
+	}
+	for _, sh := range h.shards {
+		if sh.connections == nil || sh.queue == nil {
+			t.Fatal("分片未初始化")
+		}
 	}
 	if h.broadcast == nil {
 		t.Error("broadcast 通道未初始化")
 	}
-	if h.register == nil {
-		t.Error("register 通道未初始化")
-	}
 	if h.unregister == nil {
 		t.Error("unregister 通道未初始化")
 	}
@@ -28,10 +41,10 @@ func TestNewHub(t *testing.T) {
 func TestHubStart(t *testing.T) {
 	h := newHub()
 
-	h.Start(true)
+	h.Start()
 
 	conn := h.newConnection()
-	h.register <- conn
+	h.registerConnection(conn)
 
 	time.Sleep(100 * time.Millisecond)
 
@@ -50,10 +63,10 @@ func TestHubStart(t *testing.T) {
 
 func TestHubBroadcast(t *testing.T) {
 	h := newHub()
-	h.Start(true)
+	h.Start()
 
 	conn := h.newConnection()
-	h.register <- conn
+	h.registerConnection(conn)
 
 	// 等待连接注册
 	time.Sleep(100 * time.Millisecond)
@@ -69,8 +82,8 @@ func TestHubBroadcast(t *testing.T) {
 	select {
 	case receivedMsg := <-conn.send:
 		expectedMsg := "event:test\ndata:Hello, World!\n\n"
-		if string(receivedMsg) != expectedMsg {
-			t.Errorf("广播的消息不匹配，得到 %s，想要 %s", string(receivedMsg), expectedMsg)
+		if string(receivedMsg.buf) != expectedMsg {
+			t.Errorf("广播的消息不匹配，得到 %s，想要 %s", string(receivedMsg.buf), expectedMsg)
 		}
 	default:
 		t.Error("未收到广播消息")
@@ -79,7 +92,7 @@ func TestHubBroadcast(t *testing.T) {
 
 func TestHubStop(t *testing.T) {
 	h := newHub()
-	h.Start(true)
+	h.Start()
 
 	// 停止 hub
 	h.Stop()
@@ -94,4 +107,204 @@ func TestHubStop(t *testing.T) {
 
 	// 尝试再次停止，确保不会 panic
 	h.Stop()
+
+	// 停止后注册的连接不会加入 hub，否则 closeAllConnections 之后它将永远不被关闭
+	conn := h.newConnection()
+	if h.registerConnection(conn) {
+		t.Error("停止后不应注册连接")
+	}
+	if h.GetActiveConnectionCount() != 0 || h.connectionByID(conn.id) != nil {
+		t.Error("停止后的连接不应出现在分片中")
+	}
+}
+
+func TestHubNamespaceRouting(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+
+	all := h.newConnection()
+	sysenv := h.newConnection()
+	sysenv.namespaces = []string{"/sysenv/update"}
+	other := h.newConnection()
+	other.namespaces = []string{"/other"}
+	h.registerConnection(all)
+	h.registerConnection(sysenv)
+	h.registerConnection(other)
+
+	waitUntil(t, time.Second, func() bool {
+		return h.GetActiveConnectionCount() == 3
+	}, "连接未全部注册")
+
+	h.broadcast <- SSEMessage{Data: []byte("env"), Namespace: "/sysenv/update"}
+	time.Sleep(100 * time.Millisecond)
+
+	expected := "namespace:/sysenv/update\ndata:env\n\n"
+	for name, conn := range map[string]*connection{"all": all, "sysenv": sysenv} {
+		select {
+		case got := <-conn.send:
+			if string(got.buf) != expected {
+				t.Errorf("%s 收到的消息不匹配，得到 %q，想要 %q", name, got.buf, expected)
+			}
+		default:
+			t.Errorf("%s 未收到 namespace 消息", name)
+		}
+	}
+	select {
+	case got := <-other.send:
+		t.Errorf("其它 namespace 的连接不应收到消息，得到 %q", got.buf)
+	default:
+	}
+
+	// 空 namespace 的消息发给所有连接
+	h.broadcast <- SSEMessage{Data: []byte("global")}
+	time.Sleep(100 * time.Millisecond)
+	for _, conn := range []*connection{all, sysenv, other} {
+		select {
+		case <-conn.send:
+		default:
+			t.Error("全局消息未送达所有连接")
+		}
+	}
+}
+
+func TestNormalizeNamespace(t *testing.T) {
+	cases := map[string]string{
+		"":                "",
+		"/":               "",
+		"/sysenv/update":  "/sysenv/update",
+		"sysenv/update/":  "/sysenv/update",
+		"/sysenv/update/": "/sysenv/update",
+	}
+	for in, want := range cases {
+		if got := normalizeNamespace(in); got != want {
+			t.Errorf("normalizeNamespace(%q) = %q，想要 %q", in, got, want)
+		}
+	}
+}
+
+func TestHubTargetedDelivery(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+
+	alice1 := h.newConnection()
+	alice1.userID = "alice"
+	alice2 := h.newConnection()
+	alice2.userID = "alice"
+	bob := h.newConnection()
+	bob.userID = "bob"
+	for _, conn := range []*connection{alice1, alice2, bob} {
+		h.registerConnection(conn)
+	}
+	waitUntil(t, time.Second, func() bool {
+		return h.GetActiveConnectionCount() == 3
+	}, "连接未全部注册")
+
+	if n := h.sendToUser("alice", SSEMessage{Data: []byte("hi")}); n != 2 {
+		t.Errorf("sendToUser 送达数错误，得到 %d，想要 2", n)
+	}
+	if n := h.sendToUser("nobody", SSEMessage{Data: []byte("hi")}); n != 0 {
+		t.Errorf("不存在的用户送达数应为 0，得到 %d", n)
+	}
+	for _, conn := range []*connection{alice1, alice2} {
+		select {
+		case <-conn.send:
+		default:
+			t.Error("alice 的连接未收到定向消息")
+		}
+	}
+	select {
+	case <-bob.send:
+		t.Error("bob 不应收到发给 alice 的消息")
+	default:
+	}
+
+	if err := h.sendToConnection(bob.id, SSEMessage{Data: []byte("only bob")}); err != nil {
+		t.Fatalf("sendToConnection 失败: %v", err)
+	}
+	if got := <-bob.send; string(got.buf) != "data:only bob\n\n" {
+		t.Errorf("定向消息内容错误，得到 %q", got.buf)
+	}
+	if err := h.sendToConnection("missing", SSEMessage{Data: []byte("x")}); err != ErrConnectionNotFound {
+		t.Errorf("期望 ErrConnectionNotFound，得到 %v", err)
+	}
+}
+
+func TestHubPublishResult(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+
+	fast := h.newConnection()
+	slow := h.newConnection()
+	slow.send = make(chan *frame, 1)
+	slow.send <- newFrame([]byte("pending"))
+	h.registerConnection(fast)
+	h.registerConnection(slow)
+	waitUntil(t, time.Second, func() bool {
+		return h.GetActiveConnectionCount() == 2
+	}, "连接未注册")
+
+	result, err := h.publish(context.Background(), SSEMessage{Data: []byte("x")})
+	if err != nil {
+		t.Fatalf("publish 失败: %v", err)
+	}
+	if result.Accepted != 1 || result.Dropped != 1 || result.Skipped != 0 {
+		t.Errorf("投递结果错误: %+v", result)
+	}
+}
+
+func TestHubPublishBackpressure(t *testing.T) {
+	// 未启动 worker 的 hub，用于构造队列积压
+	h := newHub()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := h.publish(ctx, SSEMessage{Data: []byte("x")}); err != context.DeadlineExceeded {
+		t.Errorf("等待投递超时应返回 ctx 错误，得到 %v", err)
+	}
+
+	queue := h.queueFor(&SSEMessage{})
+	for len(queue) < cap(queue) {
+		queue <- broadcastJob{}
+	}
+	if _, err := h.publish(context.Background(), SSEMessage{Data: []byte("x")}); err != ErrQueueFull {
+		t.Errorf("队列已满应返回 ErrQueueFull，得到 %v", err)
+	}
+	if h.GetDroppedMessageCount() != 1 {
+		t.Errorf("丢弃计数错误，得到 %d", h.GetDroppedMessageCount())
+	}
+
+	h.Stop()
+	if _, err := h.publish(context.Background(), SSEMessage{Data: []byte("x")}); err != ErrServerStopped {
+		t.Errorf("停止后应返回 ErrServerStopped，得到 %v", err)
+	}
+}
+
+func TestHubWildcardRouting(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+
+	subtree := h.newConnection()
+	subtree.namespaces = []string{"/device/#"}
+	telemetry := h.newConnection()
+	telemetry.namespaces = []string{"/device/*/telemetry"}
+	h.registerConnection(subtree)
+	h.registerConnection(telemetry)
+	waitUntil(t, time.Second, func() bool {
+		return h.GetActiveConnectionCount() == 2
+	}, "连接未注册")
+
+	h.broadcast <- SSEMessage{Data: []byte("t"), Namespace: "/device/42/telemetry"}
+	h.broadcast <- SSEMessage{Data: []byte("s"), Namespace: "/device/42/status"}
+	time.Sleep(100 * time.Millisecond)
+
+	if got := drainFrames(subtree); len(got) != 2 {
+		t.Errorf("/device/# 应收到 2 条消息，得到 %q", got)
+	}
+	if got := drainFrames(telemetry); len(got) != 1 || got[0] != "namespace:/device/42/telemetry\ndata:t\n\n" {
+		t.Errorf("/device/*/telemetry 收到的消息错误: %q", got)
+	}
 }
@@ -0,0 +1,81 @@
+package sseserver
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Logger 是服务端使用的分级结构化日志接口。args 为交替出现的键值对，约定与 log/slog 相同，
+// 常用的键有 conn（连接 ID）、ip、namespace 与 err。*slog.Logger 直接实现了该接口。
+// Debug 级别的日志只在开启调试（Server.SetDebug）时产生。
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// leveledLogger 在未开启调试时丢弃 Debug 级别的日志。debug 与 Server 共享并以原子操作读写，
+// 服务运行期间由 SetDebug 切换时 hub、分片与 Broker 的 goroutine 同样生效
+type leveledLogger struct {
+	Logger
+	debug *int32
+}
+
+func (l leveledLogger) Debug(msg string, args ...any) {
+	if atomic.LoadInt32(l.debug) != 0 {
+		l.Logger.Debug(msg, args...)
+	}
+}
+
+// stdLogger 以 key=value 文本格式输出到标准库 log.Logger
+type stdLogger struct {
+	l *log.Logger
+}
+
+// NewStdLogger 返回输出到 l 的 Logger，每条日志形如
+//
+//	level=INFO msg="connection opened" conn=3f2a ip=203.0.113.7
+//
+// l 为 nil 时使用标准库的默认 Logger。未配置 ServerOptions.Logger 时服务端使用 NewStdLogger(nil)。
+func NewStdLogger(l *log.Logger) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return stdLogger{l: l}
+}
+
+func (s stdLogger) Debug(msg string, args ...any) { s.output("DEBUG", msg, args) }
+func (s stdLogger) Info(msg string, args ...any)  { s.output("INFO", msg, args) }
+func (s stdLogger) Warn(msg string, args ...any)  { s.output("WARN", msg, args) }
+func (s stdLogger) Error(msg string, args ...any) { s.output("ERROR", msg, args) }
+
+func (s stdLogger) output(level, msg string, args []any) {
+	var b strings.Builder
+	b.WriteString("level=")
+	b.WriteString(level)
+	b.WriteString(" msg=")
+	b.WriteString(quoteLogValue(msg))
+	for i := 0; i < len(args); i += 2 {
+		key, value := "!BADKEY", args[i]
+		if i+1 < len(args) {
+			key, value = fmt.Sprint(args[i]), args[i+1]
+		}
+		b.WriteByte(' ')
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(quoteLogValue(fmt.Sprint(value)))
+	}
+	s.l.Output(3, b.String())
+}
+
+// quoteLogValue 在值为空或包含空白、引号、等号时加引号
+func quoteLogValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\r\n\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
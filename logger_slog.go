@@ -0,0 +1,16 @@
+//go:build go1.21
+
+package sseserver
+
+import "log/slog"
+
+var _ Logger = (*slog.Logger)(nil)
+
+// NewSlogLogger 返回输出到 slog.Handler 的 Logger，例如
+//
+//	sseserver.NewSlogLogger(slog.NewJSONHandler(os.Stderr, nil))
+//
+// 已有 *slog.Logger 时可直接赋给 ServerOptions.Logger。
+func NewSlogLogger(h slog.Handler) Logger {
+	return slog.New(h)
+}
@@ -0,0 +1,31 @@
+//go:build go1.21
+
+package sseserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	server := NewServer(ServerOptions{
+		Logger: NewSlogLogger(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	defer server.Stop()
+	server.SetDebug(true)
+
+	conn := server.hub.newConnection()
+	conn.remoteIP = "203.0.113.7"
+	server.hub.registerConnection(conn)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("slog 输出无法解析: %v %q", err, buf.String())
+	}
+	if entry["level"] != "DEBUG" || entry["msg"] != "connection registered" || entry["conn"] != conn.id || entry["ip"] != "203.0.113.7" {
+		t.Errorf("slog 结构化字段错误: %v", entry)
+	}
+}
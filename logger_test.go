@@ -0,0 +1,106 @@
+package sseserver
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordLogger 记录每条日志的级别、消息与键值对
+type recordLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordLogger) record(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := &bytes.Buffer{}
+	NewStdLogger(log.New(b, "", 0)).(stdLogger).output(level, msg, args)
+	l.entries = append(l.entries, strings.TrimSpace(b.String()))
+}
+
+func (l *recordLogger) Debug(msg string, args ...any) { l.record("DEBUG", msg, args) }
+func (l *recordLogger) Info(msg string, args ...any)  { l.record("INFO", msg, args) }
+func (l *recordLogger) Warn(msg string, args ...any)  { l.record("WARN", msg, args) }
+func (l *recordLogger) Error(msg string, args ...any) { l.record("ERROR", msg, args) }
+
+func (l *recordLogger) find(prefix string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if strings.HasPrefix(e, prefix) {
+			return e
+		}
+	}
+	return ""
+}
+
+func TestStdLoggerFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+	logger.Warn("write to client failed", "conn", "c1", "ip", "203.0.113.7", "err", errors.New("broken pipe"), "dangling")
+	want := `level=WARN msg="write to client failed" conn=c1 ip=203.0.113.7 err="broken pipe" !BADKEY=dangling` + "\n"
+	if buf.String() != want {
+		t.Errorf("日志格式错误\n得到 %q\n想要 %q", buf.String(), want)
+	}
+}
+
+func TestServerLogger(t *testing.T) {
+	logger := &recordLogger{}
+	server := NewServer(ServerOptions{Logger: logger})
+	defer server.Stop()
+
+	conn := server.hub.newConnection()
+	conn.remoteIP = "203.0.113.7"
+	server.hub.registerConnection(conn)
+	if logger.find("level=DEBUG") != "" {
+		t.Errorf("未开启 Debug 时不应输出 Debug 日志")
+	}
+
+	server.SetDebug(true)
+	conn = server.hub.newConnection()
+	conn.remoteIP = "203.0.113.7"
+	server.hub.registerConnection(conn)
+	want := "level=DEBUG msg=\"connection registered\" conn=" + conn.id + " ip=203.0.113.7 active=2"
+	if got := logger.find("level=DEBUG"); got != want {
+		t.Errorf("hub 的 Debug 日志错误\n得到 %q\n想要 %q", got, want)
+	}
+
+	rr := httptest.NewRecorder()
+	server.requestLogger(server).ServeHTTP(rr, httptest.NewRequest("GET", "/admin/stats", nil))
+	if got := logger.find("level=INFO"); got != `level=INFO msg="http request" ip=192.0.2.1 method=GET url=/admin/stats` {
+		t.Errorf("请求日志错误，得到 %q", got)
+	}
+
+	quiet := NewServer(ServerOptions{Logger: logger, DisableRequestLog: true})
+	defer quiet.Stop()
+	if h, ok := quiet.requestLogger(quiet).(*Server); !ok || h != quiet {
+		t.Errorf("DisableRequestLog 时不应包装请求日志")
+	}
+}
+
+func TestSetDebugWhileRunning(t *testing.T) {
+	server := NewServer(ServerOptions{Logger: &recordLogger{}})
+	defer server.Stop()
+
+	// hub 的 goroutine 记录 Debug 日志的同时切换调试开关，-race 下不应报告数据竞争
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			server.SetDebug(i%2 == 0)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		server.hub.broadcast <- SSEMessage{Data: []byte("x")}
+		conn := server.hub.newConnection()
+		server.hub.registerConnection(conn)
+		server.hub.unregisterConnection(conn)
+	}
+	<-done
+}
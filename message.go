@@ -1,20 +1,38 @@
 package sseserver
 
+import (
+	"strconv"
+	"time"
+)
+
 type SSEMessage struct {
-	Event     string
-	Data      []byte
-	Namespace string
+	ID          string // 事件 ID，客户端重连时通过 Last-Event-ID 回传
+	Event       string
+	Data        []byte
+	Namespace   string
+	Retry       time.Duration // 客户端断线后的重连间隔，以 retry: 行发送，0 = 不发送
+	OrderingKey string        // 排序键相同的消息按发布顺序投递，为空时使用 Namespace，不发送给客户端
 }
 
 func (msg SSEMessage) Bytes() []byte {
-	// 预计算总长度，单次分配
+	return msg.appendFrame(make([]byte, 0, msg.frameSize()))
+}
+
+// frameSize 预计算编码后的长度，用于单次分配
+func (msg SSEMessage) frameSize() int {
 	size := 0
+	if msg.ID != "" {
+		size += 3 + len(msg.ID) + 1 // "id:" + id + "\n"
+	}
 	if msg.Event != "" {
 		size += 6 + len(msg.Event) + 1 // "event:" + event + "\n"
 	}
 	if msg.Namespace != "" {
 		size += 10 + len(msg.Namespace) + 1 // "namespace:" + ns + "\n"
 	}
+	if msg.Retry > 0 {
+		size += 6 + 20 + 1 // "retry:" + 毫秒数（最多 20 位）+ "\n"
+	}
 
 	dataLen := len(msg.Data)
 	nlCount := 0
@@ -26,9 +44,16 @@ func (msg SSEMessage) Bytes() []byte {
 	lines := nlCount + 1
 	// lines * "data:" + data bytes + lines * "\n" + final "\n"
 	size += lines*5 + dataLen + lines + 1
+	return size
+}
 
-	buf := make([]byte, 0, size)
-
+// appendFrame 将编码后的帧追加到 buf
+func (msg SSEMessage) appendFrame(buf []byte) []byte {
+	if msg.ID != "" {
+		buf = append(buf, "id:"...)
+		buf = append(buf, msg.ID...)
+		buf = append(buf, '\n')
+	}
 	if msg.Event != "" {
 		buf = append(buf, "event:"...)
 		buf = append(buf, msg.Event...)
@@ -39,6 +64,9 @@ func (msg SSEMessage) Bytes() []byte {
 		buf = append(buf, msg.Namespace...)
 		buf = append(buf, '\n')
 	}
+	if msg.Retry > 0 {
+		buf = appendRetry(buf, msg.Retry)
+	}
 
 	// 直接操作 []byte，避免 string 转换
 	start := 0
@@ -58,6 +86,13 @@ func (msg SSEMessage) Bytes() []byte {
 	return buf
 }
 
+// appendRetry 追加 "retry:<毫秒>\n" 行
+func appendRetry(buf []byte, retry time.Duration) []byte {
+	buf = append(buf, "retry:"...)
+	buf = strconv.AppendInt(buf, retry.Milliseconds(), 10)
+	return append(buf, '\n')
+}
+
 // NewSSEMessage 创建一个新的 SSEMessage
 func NewSSEMessage(event string, data []byte, namespace string) SSEMessage {
 	return SSEMessage{
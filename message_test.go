@@ -3,6 +3,7 @@ package sseserver
 import (
 	"bytes"
 	"testing"
+	"time"
 )
 
 func TestSSEMessageBytes(t *testing.T) {
@@ -49,6 +50,24 @@ func TestSSEMessageBytes(t *testing.T) {
 			},
 			expected: []byte("event:empty\ndata:\n\n"),
 		},
+		{
+			name: "带 ID 的消息",
+			message: SSEMessage{
+				ID:    "42",
+				Event: "update",
+				Data:  []byte("New content"),
+			},
+			expected: []byte("id:42\nevent:update\ndata:New content\n\n"),
+		},
+		{
+			name: "带重连间隔的消息",
+			message: SSEMessage{
+				Event: "update",
+				Data:  []byte("New content"),
+				Retry: 3 * time.Second,
+			},
+			expected: []byte("event:update\nretry:3000\ndata:New content\n\n"),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,180 @@
+package sseserver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMetricsNamespaceLimit sse_namespace_connections 默认最多输出的 namespace 数
+const defaultMetricsNamespaceLimit = 50
+
+// writeLatencyBuckets 写入延迟直方图的桶上界（秒）
+var writeLatencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// histogram 无锁直方图，counts 中每个桶单独计数，输出时再累加
+type histogram struct {
+	bounds []float64
+	counts []uint64 // 最后一个元素对应 +Inf
+	sumNs  uint64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	sec := d.Seconds()
+	i := sort.SearchFloat64s(h.bounds, sec)
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.sumNs, uint64(d))
+	atomic.AddUint64(&h.count, 1)
+}
+
+type metrics struct {
+	messagesBroadcast uint64
+	bytesWritten      uint64
+	sendBufferDrops   uint64
+	registered        uint64
+	unregistered      uint64
+	writeLatency      *histogram
+}
+
+func newMetrics() *metrics {
+	return &metrics{writeLatency: newHistogram(writeLatencyBuckets)}
+}
+
+// addMetricsEndpoint 注册 /metrics，每个请求都需通过 MetricsAuthorize 校验
+func (s *Server) addMetricsEndpoint() {
+	s.mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if !s.Options.MetricsAuthorize(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		s.writeMetrics(w)
+	})
+}
+
+// writeMetrics 以 Prometheus 文本格式输出指标
+func (s *Server) writeMetrics(out io.Writer) {
+	h := s.hub
+	m := h.metrics
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+
+	writeMetric(w, "sse_active_connections", "gauge", "Number of active SSE connections.", float64(h.GetActiveConnectionCount()))
+
+	fmt.Fprintf(w, "# HELP sse_namespace_connections Number of active SSE connections by namespace.\n")
+	fmt.Fprintf(w, "# TYPE sse_namespace_connections gauge\n")
+	limit := s.Options.MetricsNamespaceLimit
+	if limit == 0 {
+		limit = defaultMetricsNamespaceLimit
+	}
+	top, other := topNamespaces(h.namespaceCounts(), limit)
+	for _, nc := range top {
+		ns := nc.namespace
+		if ns == "" {
+			ns = "/"
+		}
+		fmt.Fprintf(w, "sse_namespace_connections{namespace=\"%s\"} %d\n", escapeLabel(ns), nc.count)
+	}
+	if other > 0 {
+		fmt.Fprintf(w, "sse_namespace_connections{namespace=\"other\"} %d\n", other)
+	}
+
+	fmt.Fprintf(w, "# HELP sse_connections_rejected_total Total number of SSE connections rejected by admission control.\n")
+	fmt.Fprintf(w, "# TYPE sse_connections_rejected_total counter\n")
+	for reason, name := range rejectReasonNames {
+		fmt.Fprintf(w, "sse_connections_rejected_total{reason=\"%s\"} %d\n", name, atomic.LoadUint64(&s.admission.rejected[reason]))
+	}
+
+	writeMetric(w, "sse_messages_broadcast_total", "counter", "Total number of messages fanned out by the hub.", float64(atomic.LoadUint64(&m.messagesBroadcast)))
+	writeMetric(w, "sse_messages_dropped_total", "counter", "Total number of messages dropped because the broadcast queue was full.", float64(h.GetDroppedMessageCount()))
+	writeMetric(w, "sse_send_buffer_drops_total", "counter", "Total number of deliveries that failed because a connection send buffer was full.", float64(atomic.LoadUint64(&m.sendBufferDrops)))
+	writeMetric(w, "sse_bytes_written_total", "counter", "Total number of bytes written to SSE clients.", float64(atomic.LoadUint64(&m.bytesWritten)))
+	writeMetric(w, "sse_connections_registered_total", "counter", "Total number of registered connections.", float64(atomic.LoadUint64(&m.registered)))
+	writeMetric(w, "sse_connections_unregistered_total", "counter", "Total number of unregistered connections.", float64(atomic.LoadUint64(&m.unregistered)))
+	writeMetric(w, "sse_broadcast_channel_depth", "gauge", "Number of messages waiting in the broadcast channel.", float64(len(h.broadcast)))
+	writeMetric(w, "sse_broadcast_queue_depth", "gauge", "Number of messages waiting in the broadcast worker queue.", float64(h.broadcastQueueStats().Len))
+
+	hist := m.writeLatency
+	fmt.Fprintf(w, "# HELP sse_write_duration_seconds Latency of writing and flushing a frame to a client.\n")
+	fmt.Fprintf(w, "# TYPE sse_write_duration_seconds histogram\n")
+	var cumulative uint64
+	for i, bound := range hist.bounds {
+		cumulative += atomic.LoadUint64(&hist.counts[i])
+		fmt.Fprintf(w, "sse_write_duration_seconds_bucket{le=\"%s\"} %d\n", formatFloat(bound), cumulative)
+	}
+	cumulative += atomic.LoadUint64(&hist.counts[len(hist.bounds)])
+	fmt.Fprintf(w, "sse_write_duration_seconds_bucket{le=\"+Inf\"} %d\n", cumulative)
+	fmt.Fprintf(w, "sse_write_duration_seconds_sum %s\n", formatFloat(time.Duration(atomic.LoadUint64(&hist.sumNs)).Seconds()))
+	fmt.Fprintf(w, "sse_write_duration_seconds_count %d\n", atomic.LoadUint64(&hist.count))
+}
+
+func writeMetric(w io.Writer, name, typ, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, typ, name, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// namespaceCounts 返回每个订阅 namespace 的连接数，按 namespace 排序
+func (h *hub) namespaceCounts() []namespaceCount {
+	totals := make(map[string]int)
+	for _, sh := range h.shards {
+		sh.mu.RLock()
+		for _, nc := range sh.subscriptions.counts() {
+			totals[nc.namespace] += nc.count
+		}
+		sh.mu.RUnlock()
+	}
+	counts := make([]namespaceCount, 0, len(totals))
+	for ns, n := range totals {
+		counts = append(counts, namespaceCount{namespace: ns, count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].namespace < counts[j].namespace
+	})
+	return counts
+}
+
+// topNamespaces 保留连接数最多的 limit 个 namespace（按 namespace 排序），返回其余 namespace 的连接数合计。
+// namespace 数量不受限制（如按设备划分），全部输出会让指标基数无限增长。
+func topNamespaces(counts []namespaceCount, limit int) ([]namespaceCount, int) {
+	if limit < 0 {
+		limit = 0
+	}
+	if len(counts) <= limit {
+		return counts, 0
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].count > counts[j].count
+	})
+	other := 0
+	for _, nc := range counts[limit:] {
+		other += nc.count
+	}
+	top := counts[:limit]
+	sort.Slice(top, func(i, j int) bool {
+		return top[i].namespace < top[j].namespace
+	})
+	return top, other
+}
@@ -0,0 +1,120 @@
+package sseserver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	server := NewServer(ServerOptions{MetricsAuthorize: func(r *http.Request) bool {
+		return r.Header.Get("X-Metrics-Token") == "secret"
+	}})
+
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/subscribe/sysenv/update", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	waitUntil(t, time.Second, func() bool {
+		return server.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	server.Broadcast <- SSEMessage{Event: "env", Data: []byte("ok"), Namespace: "/sysenv/update"}
+	reader := bufio.NewReader(resp.Body)
+	for i := 0; i < 4; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			t.Fatalf("读取响应失败: %v", err)
+		}
+	}
+
+	mresp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mresp.Body.Close()
+	if mresp.StatusCode != http.StatusForbidden {
+		t.Errorf("未授权的请求应返回 403，得到 %d", mresp.StatusCode)
+	}
+	mreq, _ := http.NewRequest("GET", ts.URL+"/metrics", nil)
+	mreq.Header.Set("X-Metrics-Token", "secret")
+	mresp, err = http.DefaultClient.Do(mreq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mresp.Body.Close()
+	if ct := mresp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type 错误，得到 %q", ct)
+	}
+	body, _ := io.ReadAll(mresp.Body)
+	text := string(body)
+
+	for _, want := range []string{
+		"# TYPE sse_active_connections gauge\nsse_active_connections 1\n",
+		"sse_namespace_connections{namespace=\"/sysenv/update\"} 1\n",
+		"sse_messages_broadcast_total 1\n",
+		"sse_connections_registered_total 1\n",
+		"sse_broadcast_queue_depth 0\n",
+		"sse_write_duration_seconds_bucket{le=\"+Inf\"} 1\n",
+		"sse_write_duration_seconds_count 1\n",
+		"sse_bytes_written_total 44\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("指标输出缺少 %q\n%s", want, text)
+		}
+	}
+}
+
+func TestMetricsEndpointOptIn(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("未配置 MetricsAuthorize 时 /metrics 应返回 404，得到 %d", rr.Code)
+	}
+}
+
+func TestTopNamespaces(t *testing.T) {
+	counts := func() []namespaceCount {
+		return []namespaceCount{{"/a", 1}, {"/b", 5}, {"/c", 3}, {"/d", 5}}
+	}
+	top, other := topNamespaces(counts(), 2)
+	if len(top) != 2 || top[0].namespace != "/b" || top[1].namespace != "/d" || other != 4 {
+		t.Errorf("前 N 个 namespace 错误: %+v other=%d", top, other)
+	}
+	if top, other := topNamespaces(counts(), 10); len(top) != 4 || other != 0 {
+		t.Errorf("未超出上限时应全部输出: %+v other=%d", top, other)
+	}
+	if top, other := topNamespaces(counts(), -1); len(top) != 0 || other != 14 {
+		t.Errorf("负数上限应只输出合计: %+v other=%d", top, other)
+	}
+}
+
+func TestHistogramObserve(t *testing.T) {
+	hist := newHistogram([]float64{0.001, 0.01})
+	hist.observe(500 * time.Microsecond)
+	hist.observe(time.Millisecond)
+	hist.observe(time.Second)
+
+	if hist.counts[0] != 2 || hist.counts[1] != 0 || hist.counts[2] != 1 {
+		t.Errorf("桶计数错误: %v", hist.counts)
+	}
+	if hist.count != 3 {
+		t.Errorf("总数错误，得到 %d", hist.count)
+	}
+}
@@ -0,0 +1,57 @@
+package sseserver
+
+import "sync/atomic"
+
+// 广播队列按排序键分区：排序键相同的消息总是进入同一个分发队列，由同一个 worker 依次编码、
+// 写入消息存储并放入各分片队列，分片 worker 也按队列顺序投递，因此同一排序键的消息
+// 以进入广播队列的顺序到达每个连接。不同排序键的消息之间不保证顺序。
+
+func newBroadcastQueues(workers int) []chan broadcastJob {
+	size := broadcastQueueCapacity / workers
+	if size < 64 {
+		size = 64
+	}
+	queues := make([]chan broadcastJob, workers)
+	for i := range queues {
+		queues[i] = make(chan broadcastJob, size)
+	}
+	return queues
+}
+
+// orderingKey 返回消息的排序键，未指定时使用规范化后的 namespace
+func (msg *SSEMessage) orderingKey() string {
+	if msg.OrderingKey != "" {
+		return msg.OrderingKey
+	}
+	return normalizeNamespace(msg.Namespace)
+}
+
+// queueFor 返回消息排序键对应的分发队列
+func (h *hub) queueFor(msg *SSEMessage) chan broadcastJob {
+	if len(h.broadcastQueues) == 1 {
+		return h.broadcastQueues[0]
+	}
+	return h.broadcastQueues[fnv32a(msg.orderingKey())%uint32(len(h.broadcastQueues))]
+}
+
+// enqueue 将广播放入对应的分发队列，队列已满时丢弃并返回 false
+func (h *hub) enqueue(job broadcastJob) bool {
+	select {
+	case h.queueFor(&job.msg) <- job:
+		return true
+	default:
+		atomic.AddInt64(&h.droppedMessages, 1)
+		h.messageDropped(nil, &job.msg)
+		return false
+	}
+}
+
+// broadcastQueueStats 汇总各分发队列的长度与容量
+func (h *hub) broadcastQueueStats() queueStats {
+	var stats queueStats
+	for _, queue := range h.broadcastQueues {
+		stats.Len += len(queue)
+		stats.Cap += cap(queue)
+	}
+	return stats
+}
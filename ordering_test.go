@@ -0,0 +1,80 @@
+package sseserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// drainBroadcastQueues 取出各分发队列中的全部广播，同一队列内保持顺序
+func drainBroadcastQueues(h *hub) []broadcastJob {
+	var jobs []broadcastJob
+	for _, queue := range h.broadcastQueues {
+		for len(queue) > 0 {
+			jobs = append(jobs, <-queue)
+		}
+	}
+	return jobs
+}
+
+func TestOrderedDeliveryPerNamespace(t *testing.T) {
+	h := newHub()
+	h.sendBufferSize = 4096
+	h.Start()
+	defer h.Stop()
+
+	conn := h.newConnection()
+	conn.namespaces = []string{"/room/#"}
+	h.registerConnection(conn)
+	waitUntil(t, time.Second, func() bool {
+		return h.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	const perNamespace = 100
+	rooms := []string{"/room/1", "/room/2", "/room/3", "/room/4", "/room/5"}
+	for i := 0; i < perNamespace; i++ {
+		for _, room := range rooms {
+			h.broadcast <- SSEMessage{Namespace: room, Data: []byte(room + " " + strconv.Itoa(i))}
+		}
+	}
+	// 排序键相同但 namespace 不同的消息同样按顺序投递
+	for i := 0; i < perNamespace; i++ {
+		room := rooms[i%len(rooms)]
+		h.broadcast <- SSEMessage{Namespace: room, OrderingKey: "k", Data: []byte("k " + strconv.Itoa(i))}
+	}
+
+	next := make(map[string]int)
+	for received := 0; received < perNamespace*(len(rooms)+1); received++ {
+		select {
+		case f := <-conn.send:
+			data := strings.TrimSuffix(string(f.buf[strings.Index(string(f.buf), "data:")+5:]), "\n\n")
+			key, seq, _ := strings.Cut(data, " ")
+			if want := strconv.Itoa(next[key]); seq != want {
+				t.Fatalf("%s 的消息乱序，得到 %s，想要 %s", key, seq, want)
+			}
+			next[key]++
+			f.release()
+		case <-time.After(2 * time.Second):
+			t.Fatalf("只收到 %d 条消息", received)
+		}
+	}
+}
+
+func TestOrderingKeyPartition(t *testing.T) {
+	h := newHub()
+	if h.queueFor(&SSEMessage{Namespace: "room/1"}) != h.queueFor(&SSEMessage{Namespace: "/room/1/"}) {
+		t.Errorf("同一 namespace 的不同写法应进入同一个分发队列")
+	}
+	used := make(map[chan broadcastJob]bool)
+	for i := 0; i < 64; i++ {
+		used[h.queueFor(&SSEMessage{Namespace: "/room/1", OrderingKey: fmt.Sprint("device-", i)})] = true
+	}
+	if len(used) != len(h.broadcastQueues) {
+		t.Errorf("排序键未分散到全部分发队列，只使用了 %d 个", len(used))
+	}
+	if stats := h.broadcastQueueStats(); stats.Cap != broadcastQueueCapacity {
+		t.Errorf("分发队列总容量错误，得到 %d", stats.Cap)
+	}
+}
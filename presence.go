@@ -0,0 +1,111 @@
+package sseserver
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// PresenceMember 是订阅某个 namespace 的一个身份，同一身份的多个连接合并为一个成员
+type PresenceMember struct {
+	ID          string            `json:"id"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Connections int               `json:"connections"`
+}
+
+// presenceEvent 是 join/leave 事件的 data
+type presenceEvent struct {
+	ID string `json:"id"`
+}
+
+// Presence 返回订阅 namespace 的成员列表，按 ID 排序。
+// 成员以连接的 Principal.ID 标识，未认证时使用 UserIDFunc 解析出的用户 ID，匿名连接不计入。
+// namespace 需与订阅时的写法一致（包括通配符），不做匹配展开。
+func (s *Server) Presence(namespace string) []PresenceMember {
+	return s.hub.presenceMembers(normalizeNamespace(namespace))
+}
+
+func (h *hub) presenceMembers(namespace string) []PresenceMember {
+	h.presenceMu.Lock()
+	members := h.presence[namespace]
+	out := make([]PresenceMember, 0, len(members))
+	for _, m := range members {
+		out = append(out, *m)
+	}
+	h.presenceMu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// memberID 返回连接在 presence 中的身份，空字符串表示匿名
+func (c *connection) memberID() string {
+	if c.principal.ID != "" {
+		return c.principal.ID
+	}
+	return c.userID
+}
+
+// presenceJoin 将连接计入 namespaces 的成员
+func (h *hub) presenceJoin(conn *connection, namespaces []string) {
+	id := conn.memberID()
+	if id == "" {
+		return
+	}
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+	for _, ns := range namespaces {
+		members := h.presence[ns]
+		if members == nil {
+			members = make(map[string]*PresenceMember)
+			h.presence[ns] = members
+		}
+		m := members[id]
+		if m == nil {
+			m = &PresenceMember{ID: id, Attributes: conn.principal.Attributes}
+			members[id] = m
+		}
+		m.Connections++
+		if m.Connections == 1 {
+			h.queuePresenceEvent("join", ns, id)
+		}
+	}
+}
+
+// presenceLeave 将连接从 namespaces 的成员中移除
+func (h *hub) presenceLeave(conn *connection, namespaces []string) {
+	id := conn.memberID()
+	if id == "" {
+		return
+	}
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+	for _, ns := range namespaces {
+		m := h.presence[ns][id]
+		if m == nil {
+			continue
+		}
+		m.Connections--
+		if m.Connections > 0 {
+			continue
+		}
+		delete(h.presence[ns], id)
+		if len(h.presence[ns]) == 0 {
+			delete(h.presence, ns)
+		}
+		h.queuePresenceEvent("leave", ns, id)
+	}
+}
+
+// queuePresenceEvent 将 join/leave 事件放入广播队列。调用方持有分片锁，因此不能同步广播；
+// 队列已满时丢弃事件并计入 droppedMessages。根订阅与通配符订阅不是具体的 namespace，不发送事件。
+func (h *hub) queuePresenceEvent(event, namespace, id string) {
+	if !h.presenceEvents || namespace == "" || strings.ContainsAny(namespace, wildcardOne+wildcardRest) {
+		return
+	}
+	data, _ := json.Marshal(presenceEvent{ID: id})
+	msg := SSEMessage{Event: event, Namespace: namespace, Data: data}
+	h.assignID(&msg)
+	h.enqueue(broadcastJob{msg: msg})
+}
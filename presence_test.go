@@ -0,0 +1,66 @@
+package sseserver
+
+import (
+	"testing"
+)
+
+func TestPresence(t *testing.T) {
+	server := &Server{hub: newHub()}
+	h := server.hub
+	h.presenceEvents = true
+
+	newConn := func(id string, namespaces ...string) *connection {
+		conn := h.newConnection()
+		conn.principal = Principal{ID: id}
+		conn.namespaces = namespaces
+		h.registerConnection(conn)
+		return conn
+	}
+	a1 := newConn("alice", "/room/1")
+	a2 := newConn("alice", "/room/1", "/room/#")
+	newConn("bob", "/room/1")
+	newConn("", "/room/1")
+
+	members := server.Presence("room/1")
+	if len(members) != 2 || members[0].ID != "alice" || members[0].Connections != 2 || members[1].ID != "bob" {
+		t.Fatalf("成员列表错误: %+v", members)
+	}
+
+	// 同一身份的第二个连接不再产生 join，通配符订阅不产生事件
+	var events []string
+	for _, job := range drainBroadcastQueues(h) {
+		events = append(events, job.msg.Event+" "+job.msg.Namespace+" "+string(job.msg.Data))
+	}
+	if len(events) != 2 || events[0] != `join /room/1 {"id":"alice"}` || events[1] != `join /room/1 {"id":"bob"}` {
+		t.Fatalf("join 事件错误: %q", events)
+	}
+
+	h.unregisterConnection(a1)
+	if len(drainBroadcastQueues(h)) != 0 {
+		t.Errorf("成员仍有其它连接时不应发送 leave")
+	}
+	h.unregisterConnection(a2)
+	if jobs := drainBroadcastQueues(h); len(jobs) != 1 || jobs[0].msg.Event != "leave" || string(jobs[0].msg.Data) != `{"id":"alice"}` {
+		t.Errorf("leave 事件错误: %+v", jobs)
+	}
+	if members := server.Presence("/room/1"); len(members) != 1 || members[0].ID != "bob" {
+		t.Errorf("离开后成员列表错误: %+v", members)
+	}
+	if members := server.Presence("/room/#"); len(members) != 0 {
+		t.Errorf("空 namespace 应被清理: %+v", members)
+	}
+
+	// 动态增删订阅同样更新成员
+	bob := newConn("bob2", "/room/2")
+	drainBroadcastQueues(h)
+	if _, err := h.updateSubscriptions(bob, []string{"/room/3"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if members := server.Presence("/room/2"); len(members) != 1 {
+		t.Errorf("增加订阅后原 namespace 的成员不应丢失: %+v", members)
+	}
+	h.updateSubscriptions(bob, nil, []string{"/room/2"})
+	if len(server.Presence("/room/2")) != 0 || len(server.Presence("/room/3")) != 1 {
+		t.Errorf("取消订阅后成员列表错误")
+	}
+}
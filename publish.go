@@ -0,0 +1,270 @@
+package sseserver
+
+import (
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxPublishBodySize         = 4 << 20
+	defaultPublishSignatureAge = 5 * time.Minute
+)
+
+var errLineBreak = errors.New("field must not contain CR or LF")
+
+// publishRequest 是 /publish 接口 JSON 格式的单条消息。
+// data 为字符串时按原文发送，为其它 JSON 值时发送其 JSON 编码。
+type publishRequest struct {
+	ID          string          `json:"id"`
+	Event       string          `json:"event"`
+	Namespace   string          `json:"namespace"`
+	Data        json.RawMessage `json:"data"`
+	Retry       int64           `json:"retry"` // 毫秒
+	OrderingKey string          `json:"ordering_key"`
+}
+
+func (p publishRequest) message() (SSEMessage, error) {
+	msg := SSEMessage{
+		ID:          p.ID,
+		Event:       p.Event,
+		Namespace:   p.Namespace,
+		Retry:       time.Duration(p.Retry) * time.Millisecond,
+		OrderingKey: p.OrderingKey,
+	}
+	if len(p.Data) > 0 && p.Data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(p.Data, &s); err != nil {
+			return msg, err
+		}
+		msg.Data = []byte(s)
+	} else if len(p.Data) > 0 && string(p.Data) != "null" {
+		msg.Data = []byte(p.Data)
+	}
+	return msg, nil
+}
+
+// addPublishEndpoint 注册 /publish 接口，供非 Go 的生产者通过 HTTP 推送消息：
+//
+//	Content-Type: application/json  请求体为单条消息对象或消息数组（批量）
+//	其它 Content-Type               请求体即 data，event/namespace/id/ordering_key 取自查询参数
+//
+// 请求需携带 Authorization: Bearer <PublishSecret>，或 X-SSE-Timestamp 与 X-SSE-Signature 签名头：
+//
+//	X-SSE-Timestamp: <Unix 秒>
+//	X-SSE-Signature: sha256=<HMAC-SHA256(PublishSecret, 时间戳 "\n" 方法 "\n" 路径 "\n" 原始查询串 "\n" 请求体) 的十六进制值>
+//
+// 签名覆盖查询参数，原始格式中取自查询参数的字段无法被篡改；时间戳与当前时间相差超过
+// PublishSignatureMaxAge 的请求以及重复使用的签名均被拒绝。
+//
+// 消息逐条经 Publish 进入广播队列，成功时返回 202 {"published": n}；队列已满或服务器已停止时
+// 返回 503，published 为此前已发布的条数。
+func (s *Server) addPublishEndpoint() {
+	s.publishSignatures = newSignatureCache()
+	s.mux.HandleFunc("/publish", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPublishBodySize))
+		if err != nil {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if !s.verifyPublish(r, body) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		msgs, err := parsePublishBody(r, body)
+		if err != nil {
+			http.Error(w, "Invalid message: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for i, msg := range msgs {
+			if _, err := s.Publish(r.Context(), msg); err != nil {
+				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+					return
+				}
+				if err == ErrQueueFull || err == ErrServerStopped {
+					writeJSON(w, http.StatusServiceUnavailable, map[string]int{"published": i})
+					return
+				}
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+		writeJSON(w, http.StatusAccepted, map[string]int{"published": len(msgs)})
+	})
+}
+
+// verifyPublish 校验共享密钥或 HMAC 签名，比较均为常量时间
+func (s *Server) verifyPublish(r *http.Request, body []byte) bool {
+	secret := []byte(s.Options.PublishSecret)
+	if sig := r.Header.Get("X-SSE-Signature"); sig != "" {
+		got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+		if err != nil {
+			return false
+		}
+		timestamp := r.Header.Get("X-SSE-Timestamp")
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return false
+		}
+		maxAge := s.Options.PublishSignatureMaxAge
+		if maxAge <= 0 {
+			maxAge = defaultPublishSignatureAge
+		}
+		if age := time.Since(time.Unix(ts, 0)); age > maxAge || age < -maxAge {
+			return false
+		}
+		if !hmac.Equal(got, signPublish(secret, timestamp, r, body)) {
+			return false
+		}
+		return s.publishSignatures.use(string(got), time.Unix(ts, 0).Add(maxAge))
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), secret) == 1
+}
+
+// signPublish 计算请求的签名，覆盖时间戳、方法、路径、原始查询串与请求体
+func signPublish(secret []byte, timestamp string, r *http.Request, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	io.WriteString(mac, timestamp+"\n"+r.Method+"\n"+r.URL.EscapedPath()+"\n"+r.URL.RawQuery+"\n")
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func parsePublishBody(r *http.Request, body []byte) ([]SSEMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		q := r.URL.Query()
+		msg := SSEMessage{
+			ID:          q.Get("id"),
+			Event:       q.Get("event"),
+			Namespace:   q.Get("namespace"),
+			Data:        body,
+			OrderingKey: q.Get("ordering_key"),
+		}
+		if retry := q.Get("retry"); retry != "" {
+			ms, err := strconv.ParseInt(retry, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			msg.Retry = time.Duration(ms) * time.Millisecond
+		}
+		if err := checkLineBreaks(&msg); err != nil {
+			return nil, err
+		}
+		return []SSEMessage{msg}, nil
+	}
+
+	var reqs []publishRequest
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			return nil, err
+		}
+	} else {
+		var req publishRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+
+	msgs := make([]SSEMessage, 0, len(reqs))
+	for _, req := range reqs {
+		msg, err := req.message()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkLineBreaks(&msg); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// checkLineBreaks 拒绝会改写事件流的换行：id、event、namespace 与 ordering_key 按单行写出，
+// 不能含 CR 或 LF；data 按 LF 拆分为多行，不能含不跟 LF 的 CR（客户端会将其视为行结束）。
+func checkLineBreaks(msg *SSEMessage) error {
+	fields := []struct{ name, value string }{
+		{"id", msg.ID},
+		{"event", msg.Event},
+		{"namespace", msg.Namespace},
+		{"ordering_key", msg.OrderingKey},
+	}
+	for _, f := range fields {
+		if strings.ContainsAny(f.value, "\r\n") {
+			return fmt.Errorf("%s: %w", f.name, errLineBreak)
+		}
+	}
+	for i, b := range msg.Data {
+		if b == '\r' && (i+1 == len(msg.Data) || msg.Data[i+1] != '\n') {
+			return fmt.Errorf("data: %w", errLineBreak)
+		}
+	}
+	return nil
+}
+
+// signatureCache 记录已使用的签名直到其时间戳超出 PublishSignatureMaxAge，
+// 在此之前重复出现的签名视为重放。过期时间按最小堆排列，每次使用时清理已过期的签名。
+type signatureCache struct {
+	mu      sync.Mutex
+	seen    map[string]struct{}
+	expires signatureHeap
+}
+
+func newSignatureCache() *signatureCache {
+	return &signatureCache{seen: make(map[string]struct{})}
+}
+
+// use 记录签名并返回 true；签名仍在有效期内已被使用过时返回 false
+func (c *signatureCache) use(sig string, expires time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for len(c.expires) > 0 && c.expires[0].at.Before(now) {
+		delete(c.seen, heap.Pop(&c.expires).(usedSignature).sig)
+	}
+	if _, ok := c.seen[sig]; ok {
+		return false
+	}
+	c.seen[sig] = struct{}{}
+	heap.Push(&c.expires, usedSignature{sig: sig, at: expires})
+	return true
+}
+
+type usedSignature struct {
+	sig string
+	at  time.Time
+}
+
+type signatureHeap []usedSignature
+
+func (h signatureHeap) Len() int           { return len(h) }
+func (h signatureHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h signatureHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *signatureHeap) Push(x any)        { *h = append(*h, x.(usedSignature)) }
+func (h *signatureHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
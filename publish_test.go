@@ -0,0 +1,219 @@
+package sseserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublishEndpoint(t *testing.T) {
+	server := NewServer(ServerOptions{PublishSecret: "s3cret"})
+	defer server.Stop()
+	h := server.hub
+
+	conn := h.newConnection()
+	h.registerConnection(conn)
+	waitUntil(t, time.Second, func() bool {
+		return h.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	send := func(req *http.Request) int {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	publish := func(contentType, target, body string, sign bool, token string) int {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if sign {
+			signRequest(req, "s3cret", time.Now(), body)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return send(req)
+	}
+	next := func() string {
+		select {
+		case frame := <-conn.send:
+			return string(frame.buf)
+		case <-time.After(time.Second):
+			return ""
+		}
+	}
+
+	if code := publish("text/plain", "/publish", "x", false, "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("错误密钥应返回 401，得到 %d", code)
+	}
+
+	if code := publish("text/plain", "/publish?event=raw&id=r1", "hello", false, "s3cret"); code != http.StatusAccepted {
+		t.Fatalf("原始格式发布失败，状态码 %d", code)
+	}
+	if got := next(); got != "id:r1\nevent:raw\ndata:hello\n\n" {
+		t.Errorf("原始格式消息错误，得到 %q", got)
+	}
+
+	body := `{"event":"alarm","data":{"level":2}}`
+	if code := publish("application/json", "/publish", body, true, ""); code != http.StatusAccepted {
+		t.Fatalf("JSON 格式发布失败，状态码 %d", code)
+	}
+	if got := next(); got != "event:alarm\ndata:{\"level\":2}\n\n" {
+		t.Errorf("JSON 格式消息错误，得到 %q", got)
+	}
+
+	batch := `[{"event":"a","data":"one"},{"event":"b","data":"two"}]`
+	if code := publish("application/json; charset=utf-8", "/publish", batch, true, ""); code != http.StatusAccepted {
+		t.Fatalf("批量发布失败，状态码 %d", code)
+	}
+	got := []string{next(), next()}
+	if strings.Join(got, "") != "event:a\ndata:one\n\nevent:b\ndata:two\n\n" {
+		t.Errorf("批量消息应按顺序投递，得到 %q", got)
+	}
+
+	if code := publish("application/json", "/publish", `{"data":`, false, "s3cret"); code != http.StatusBadRequest {
+		t.Errorf("非法 JSON 应返回 400，得到 %d", code)
+	}
+
+	// 单行字段中的换行与 data 中单独的 CR 会伪造事件，均应拒绝
+	injections := []struct{ contentType, target, body string }{
+		{"application/json", "/publish", `{"event":"a\ndata:evil\n\nevent:b","data":"x"}`},
+		{"application/json", "/publish", `[{"data":"ok"},{"id":"1\r2","data":"x"}]`},
+		{"application/json", "/publish", `{"namespace":"/a\n","data":"x"}`},
+		{"application/json", "/publish", `{"ordering_key":"k\r","data":"x"}`},
+		{"application/json", "/publish", `{"data":"a\rdata:evil"}`},
+		{"text/plain", "/publish?event=a%0Adata:evil", "x"},
+		{"text/plain", "/publish?namespace=%2Fa%0D", "x"},
+		{"text/plain", "/publish", "a\rb"},
+	}
+	for _, tt := range injections {
+		if code := publish(tt.contentType, tt.target, tt.body, false, "s3cret"); code != http.StatusBadRequest {
+			t.Errorf("含换行的消息 %s %q 应返回 400，得到 %d", tt.target, tt.body, code)
+		}
+	}
+	if code := publish("text/plain", "/publish", "a\r\nb", false, "s3cret"); code != http.StatusAccepted {
+		t.Errorf("data 中的 CRLF 应被接受，得到 %d", code)
+	}
+	if got := next(); got != "data:a\r\ndata:b\n\n" {
+		t.Errorf("CRLF 消息错误，得到 %q", got)
+	}
+
+	// 签名覆盖查询参数：篡改原始格式的 namespace 后签名失效
+	req := httptest.NewRequest("POST", "/publish?event=raw&namespace=/a", strings.NewReader("signed"))
+	signRequest(req, "s3cret", time.Now(), "signed")
+	if code := send(req); code != http.StatusAccepted {
+		t.Fatalf("签名的原始格式发布失败，状态码 %d", code)
+	}
+	if got := next(); got != "event:raw\nnamespace:/a\ndata:signed\n\n" {
+		t.Errorf("签名的原始格式消息错误，得到 %q", got)
+	}
+	replayed := httptest.NewRequest("POST", "/publish?event=raw&namespace=/a", strings.NewReader("signed"))
+	replayed.Header = req.Header.Clone()
+	if code := send(replayed); code != http.StatusUnauthorized {
+		t.Errorf("重放的签名请求应返回 401，得到 %d", code)
+	}
+	tampered := httptest.NewRequest("POST", "/publish?event=raw&namespace=/b", strings.NewReader("signed"))
+	signRequest(tampered, "s3cret", time.Now().Add(time.Second), "signed")
+	tampered.URL.RawQuery = "event=raw&namespace=/c"
+	if code := send(tampered); code != http.StatusUnauthorized {
+		t.Errorf("篡改查询参数的签名请求应返回 401，得到 %d", code)
+	}
+	stale := httptest.NewRequest("POST", "/publish", strings.NewReader("old"))
+	signRequest(stale, "s3cret", time.Now().Add(-10*time.Minute), "old")
+	if code := send(stale); code != http.StatusUnauthorized {
+		t.Errorf("时间戳过期的签名请求应返回 401，得到 %d", code)
+	}
+	unstamped := httptest.NewRequest("POST", "/publish", strings.NewReader("x"))
+	signRequest(unstamped, "s3cret", time.Now(), "x")
+	unstamped.Header.Del("X-SSE-Timestamp")
+	if code := send(unstamped); code != http.StatusUnauthorized {
+		t.Errorf("缺少时间戳的签名请求应返回 401，得到 %d", code)
+	}
+}
+
+func TestPublishEndpointQueueFull(t *testing.T) {
+	store := &blockingStore{release: make(chan struct{})}
+	server := NewServer(ServerOptions{PublishSecret: "s3cret", BroadcastWorkers: 1, MessageStore: store})
+	defer server.Stop()
+	defer close(store.release)
+
+	// 分发 worker 阻塞在消息存储上，之后放入的广播积压在队列中
+	queue := server.hub.queueFor(&SSEMessage{})
+	queue <- broadcastJob{msg: SSEMessage{Data: []byte("blocked")}}
+	waitUntil(t, time.Second, func() bool { return len(queue) == 0 }, "worker 未取走消息")
+	for len(queue) < cap(queue) {
+		queue <- broadcastJob{msg: SSEMessage{Data: []byte("queued")}}
+	}
+
+	req := httptest.NewRequest("POST", "/publish", strings.NewReader(`[{"data":"a"},{"data":"b"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("队列已满应返回 503，得到 %d", rr.Code)
+	}
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"published":0}` {
+		t.Errorf("响应应包含已发布条数，得到 %s", body)
+	}
+	if server.GetDroppedMessageCount() != 1 {
+		t.Errorf("丢弃计数错误，得到 %d", server.GetDroppedMessageCount())
+	}
+}
+
+// blockingStore 的 Append 在 release 关闭前阻塞
+type blockingStore struct {
+	release chan struct{}
+}
+
+func (s *blockingStore) Append(SSEMessage) error {
+	<-s.release
+	return nil
+}
+
+func (s *blockingStore) ReadSince([]string, string, string) ([]SSEMessage, error) { return nil, nil }
+func (s *blockingStore) Trim() error                                              { return nil }
+
+func TestSignatureCacheExpiry(t *testing.T) {
+	c := newSignatureCache()
+	now := time.Now()
+	if !c.use("a", now.Add(time.Hour)) || c.use("a", now.Add(time.Hour)) {
+		t.Error("有效期内的签名只能使用一次")
+	}
+	for i := 0; i < 100000; i++ {
+		c.use(strconv.Itoa(i), now.Add(time.Hour))
+	}
+	if c.use("a", now.Add(time.Hour)) {
+		t.Error("大量签名之后有效期内的旧签名仍应被拒绝")
+	}
+	c.use("old", now.Add(-time.Second))
+	c.use("b", now.Add(time.Hour))
+	if _, ok := c.seen["old"]; ok {
+		t.Error("过期的签名应被清理")
+	}
+}
+
+// signRequest 按 /publish 的签名规则为请求设置 X-SSE-Timestamp 与 X-SSE-Signature
+func signRequest(req *http.Request, secret string, at time.Time, body string) {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + req.Method + "\n" + req.URL.EscapedPath() + "\n" + req.URL.RawQuery + "\n" + body))
+	req.Header.Set("X-SSE-Timestamp", timestamp)
+	req.Header.Set("X-SSE-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestPublishEndpointDisabled(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("POST", "/publish", strings.NewReader("x")))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("未配置 PublishSecret 时 /publish 应返回 404，得到 %d", rr.Code)
+	}
+}
@@ -0,0 +1,401 @@
+package sseserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRedisTimeout      = 5 * time.Second
+	defaultRedisPingInterval = 30 * time.Second
+)
+
+// RedisBroker 基于 Redis PUBLISH/SUBSCRIBE 的 Broker，直接实现 RESP 协议，不依赖第三方客户端。
+// 订阅连接断开后会自动重连，重连期间其它节点发布的消息会丢失。
+type RedisBroker struct {
+	Addr           string
+	Channel        string
+	Password       string        // 非空时连接后先执行 AUTH
+	DialTimeout    time.Duration // 0 = 默认 5s
+	Timeout        time.Duration // 单条命令的读写超时，0 = 默认 5s
+	PingInterval   time.Duration // 订阅连接发送 PING 检测断线的间隔，0 = 默认 30s
+	ReconnectDelay time.Duration // 订阅连接断开后的重连间隔，0 = 默认 1s
+	Logger         Logger        // 记录订阅连接的断开与重连，nil = 以 key=value 文本输出到标准库默认 log.Logger
+
+	pubOnce sync.Once
+	pubSem  chan struct{} // 容量为 1，持有期间独占发布连接；等待时可响应 ctx
+	pubConn net.Conn
+	pubR    *bufio.Reader
+
+	subsMu  sync.Mutex
+	cancels []func()
+}
+
+var _ ContextPublisher = (*RedisBroker)(nil)
+
+func NewRedisBroker(addr, channel string) *RedisBroker {
+	return &RedisBroker{
+		Addr:    addr,
+		Channel: channel,
+	}
+}
+
+func (b *RedisBroker) Publish(msg SSEMessage) error {
+	return b.PublishContext(context.Background(), msg)
+}
+
+// PublishContext 实现 ContextPublisher。等待发布连接与执行命令期间 ctx 结束时返回 ctx.Err()，
+// 每条命令的读写不超过 Timeout。
+func (b *RedisBroker) PublishContext(ctx context.Context, msg SSEMessage) error {
+	payload, err := encodeBrokerMessage(msg)
+	if err != nil {
+		return err
+	}
+	if err := b.lockPublisher(ctx); err != nil {
+		return err
+	}
+	defer b.unlockPublisher()
+
+	// 连接可能已被服务端关闭，失败时重新建立连接再试一次
+	for attempt := 0; attempt < 2; attempt++ {
+		if b.pubConn == nil {
+			conn, r, err := b.dial(ctx)
+			if err != nil {
+				return err
+			}
+			b.pubConn, b.pubR = conn, r
+		}
+		err = b.roundTrip(ctx, b.pubConn, b.pubR, "PUBLISH", []byte(b.Channel), payload)
+		if err == nil {
+			return nil
+		}
+		var respErr respError
+		if errors.As(err, &respErr) {
+			return err
+		}
+		b.pubConn.Close()
+		b.pubConn, b.pubR = nil, nil
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (b *RedisBroker) lockPublisher(ctx context.Context) error {
+	b.pubOnce.Do(func() {
+		b.pubSem = make(chan struct{}, 1)
+	})
+	select {
+	case b.pubSem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *RedisBroker) unlockPublisher() {
+	<-b.pubSem
+}
+
+// roundTrip 发送命令并读取一个回复，截止时间取 Timeout 与 ctx 截止时间中较早的一个，
+// ctx 提前取消时中断阻塞的读写
+func (b *RedisBroker) roundTrip(ctx context.Context, conn net.Conn, r *bufio.Reader, name string, args ...[]byte) error {
+	deadline := time.Now().Add(b.timeout())
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	defer interruptOnDone(ctx, conn)()
+	if err := writeRESPCommand(conn, name, args...); err != nil {
+		return err
+	}
+	_, err := readRESP(r)
+	return err
+}
+
+// interruptOnDone 在 ctx 结束时把连接的截止时间设为过去，使阻塞的读写立即返回。
+// 返回的函数等待监视结束，之后不会再修改截止时间。
+func interruptOnDone(ctx context.Context, conn net.Conn) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+func (b *RedisBroker) Subscribe(handler func(SSEMessage)) (func(), error) {
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var connMu sync.Mutex
+	var current net.Conn
+	logger := b.logger()
+
+	go func() {
+		lost := false
+		for {
+			conn, r, err := b.dial(context.Background())
+			if err != nil {
+				logger.Warn("redis subscription dial failed", "addr", b.Addr, "channel", b.Channel, "err", err)
+				lost = true
+			} else {
+				connMu.Lock()
+				current = conn
+				connMu.Unlock()
+				select {
+				case <-stop:
+					conn.Close()
+					return
+				default:
+				}
+				err = b.readSubscription(conn, r, handler, func() {
+					if lost {
+						logger.Info("redis subscription restored", "addr", b.Addr, "channel", b.Channel)
+						lost = false
+					}
+				})
+				conn.Close()
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				logger.Warn("redis subscription lost, reconnecting", "addr", b.Addr, "channel", b.Channel, "err", err)
+				lost = true
+			}
+
+			delay := b.ReconnectDelay
+			if delay <= 0 {
+				delay = time.Second
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
+			}
+		}
+	}()
+
+	cancel := func() {
+		stopOnce.Do(func() {
+			close(stop)
+			connMu.Lock()
+			if current != nil {
+				current.Close()
+			}
+			connMu.Unlock()
+		})
+	}
+	b.subsMu.Lock()
+	b.cancels = append(b.cancels, cancel)
+	b.subsMu.Unlock()
+	return cancel, nil
+}
+
+// readSubscription 订阅频道并持续读取消息，直到连接出错。订阅确认后调用 subscribed。
+// 每隔 PingInterval 发送一次 PING，超过 PingInterval 加 Timeout 没有收到任何回复即视为连接已断开。
+func (b *RedisBroker) readSubscription(conn net.Conn, r *bufio.Reader, handler func(SSEMessage), subscribed func()) error {
+	timeout := b.timeout()
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if err := writeRESPCommand(conn, "SUBSCRIBE", []byte(b.Channel)); err != nil {
+		return err
+	}
+	interval := b.PingInterval
+	if interval <= 0 {
+		interval = defaultRedisPingInterval
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(timeout))
+				if writeRESPCommand(conn, "PING") != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(interval + timeout))
+		reply, err := readRESP(r)
+		if err != nil {
+			return err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) == 0 {
+			continue
+		}
+		kind, _ := parts[0].([]byte)
+		if string(kind) == "subscribe" {
+			subscribed()
+			continue
+		}
+		if string(kind) != "message" || len(parts) != 3 {
+			continue
+		}
+		payload, _ := parts[2].([]byte)
+		msg, err := decodeBrokerMessage(payload)
+		if err != nil {
+			continue
+		}
+		handler(msg)
+	}
+}
+
+// Close 关闭发布连接并取消所有订阅
+func (b *RedisBroker) Close() error {
+	b.subsMu.Lock()
+	cancels := b.cancels
+	b.cancels = nil
+	b.subsMu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+	b.lockPublisher(context.Background())
+	defer b.unlockPublisher()
+	if b.pubConn != nil {
+		err := b.pubConn.Close()
+		b.pubConn, b.pubR = nil, nil
+		return err
+	}
+	return nil
+}
+
+func (b *RedisBroker) dial(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	timeout := b.DialTimeout
+	if timeout <= 0 {
+		timeout = defaultRedisTimeout
+	}
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", b.Addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := bufio.NewReader(conn)
+	if b.Password != "" {
+		if err := b.roundTrip(ctx, conn, r, "AUTH", []byte(b.Password)); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	return conn, r, nil
+}
+
+func (b *RedisBroker) timeout() time.Duration {
+	if b.Timeout > 0 {
+		return b.Timeout
+	}
+	return defaultRedisTimeout
+}
+
+func (b *RedisBroker) logger() Logger {
+	if b.Logger != nil {
+		return b.Logger
+	}
+	return NewStdLogger(nil)
+}
+
+// respError 是 Redis 返回的错误回复
+type respError string
+
+func (e respError) Error() string {
+	return "redis: " + string(e)
+}
+
+// writeRESPCommand 以 RESP 数组格式写出命令
+func writeRESPCommand(w io.Writer, name string, args ...[]byte) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)+1), 10)
+	buf = append(buf, "\r\n"...)
+	buf = appendRESPBulk(buf, []byte(name))
+	for _, arg := range args {
+		buf = appendRESPBulk(buf, arg)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func appendRESPBulk(buf, b []byte) []byte {
+	buf = append(buf, '$')
+	buf = strconv.AppendInt(buf, int64(len(b)), 10)
+	buf = append(buf, "\r\n"...)
+	buf = append(buf, b...)
+	return append(buf, "\r\n"...)
+}
+
+// readRESP 读取一个 RESP 回复：简单字符串与批量字符串返回 []byte，整数返回 int64，
+// 数组返回 []any，空值返回 nil，错误回复以 respError 返回。
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	body := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, respError(body)
+	case ':':
+		return strconv.ParseInt(string(body), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(body))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(body))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+}
@@ -2,40 +2,141 @@ package sseserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	ErrConnectionNotFound = errors.New("sse: connection not found")
+	ErrSendBufferFull     = errors.New("sse: connection send buffer full")
+	ErrQueueFull          = errors.New("sse: broadcast queue full")
+	ErrServerStopped      = errors.New("sse: server stopped")
+	ErrNamespaceLimit     = errors.New("sse: namespace connection limit reached")
+)
+
+// PublishResult 是一条广播消息在本节点的投递结果
+type PublishResult struct {
+	Accepted int // 消息已放入发送缓冲的连接数
+	Skipped  int // 已关闭而未投递的连接数
+	Dropped  int // 因发送缓冲已满而丢弃该消息或被断开的连接数
+}
+
 type Server struct {
 	Broadcast chan<- SSEMessage
 	Options   ServerOptions
 	hub       *hub
 	mux       *http.ServeMux
 	stopChan  chan struct{}
+	// Debug 在 Serve 或 ServeListener 启动时读取一次，为 true 时开启调试日志；
+	// 服务运行期间开启或关闭请使用 SetDebug
 	Debug     bool
+	debug     int32 // 原子读写，由 leveledLogger 共享
 	closeOnce sync.Once
 	server    *http.Server
 
 	heartbeatData []byte
 
-	ipConns   map[string]int32
-	ipConnsMu sync.Mutex
+	logger         Logger
+	admission      *admission
+	trustedProxies []*net.IPNet
+
+	rng   *rand.Rand
+	rngMu sync.Mutex
+
+	nodeID string
+	dedup  *dedup
+
+	publishSignatures *signatureCache // 有效期内已使用的 /publish 签名
 }
 
 type ServerOptions struct {
-	DisableAdminEndpoints bool
-	CorsOptions           *CorsOptions
-	HeartbeatInterval     time.Duration // 心跳间隔，0 = 禁用（默认）
-	MaxConnectionsPerIP   int           // 单 IP 最大连接数，0 = 不限制（默认）
-	BroadcastWorkers      int           // 广播 worker 数量，0 = 默认 4
-	ShutdownTimeout       time.Duration // 优雅关闭超时，0 = 默认 5s
-	IdleTimeout           time.Duration // 空闲连接超时，0 = 默认 30s
+	DisableAdminEndpoints      bool // 在配置了 AdminAuthorize 时仍不注册 /admin/ 管理接口
+	CorsOptions                *CorsOptions
+	HeartbeatInterval          time.Duration // 心跳间隔，0 = 禁用（默认）
+	MaxConnections             int           // 全局最大连接数，0 = 默认 10000，负数 = 不限制
+	MaxConnectionsPerIP        int           // 单 IP 最大连接数，0 = 不限制（默认）
+	MaxConnectionsPerPrincipal int           // 单个身份（Principal.ID，未认证时为 UserIDFunc 的结果）最大连接数，0 = 不限制（默认）
+	MaxConnectionsPerNamespace int           // 单个订阅 namespace 最大连接数，0 = 不限制（默认）
+	ConnectionRate             float64       // 每秒允许建立的新连接数（令牌桶），0 = 不限制（默认）
+	ConnectionBurst            int           // 令牌桶容量，即允许瞬时建立的连接数，0 = ConnectionRate 向上取整
+	AdmissionRetryAfter        time.Duration // 因连接数达到上限拒绝时 Retry-After 的值，0 = 默认 5s
+	// TrustedProxies 可信反向代理的 IP 或 CIDR（如 "10.0.0.0/8"）。只有来自这些地址的请求才会采用
+	// Forwarded、X-Forwarded-For 或 X-Real-IP 中的客户端 IP，为空时始终使用 TCP 连接的对端地址。
+	TrustedProxies    []string
+	BroadcastWorkers  int           // 广播分发 worker 数量，0 = 默认 4
+	HubShards         int           // hub 分片数量，每个分片有独立的锁与投递 worker，0 = 默认 16
+	ShutdownTimeout   time.Duration // 优雅关闭超时，0 = 默认 5s
+	IdleTimeout       time.Duration // 空闲连接超时，0 = 默认 30s
+	HistorySize       int           // 每个 namespace 保留的历史消息数，用于 Last-Event-ID 补发，0 = 禁用（默认）
+	MessageStore      MessageStore  // 自定义消息存储，设置后忽略 HistorySize，如 NewFileStore 可在重启后继续补发
+	MaxReplayMessages int           // 重连时最多补发的消息数，超出时只补发最近的部分，0 = 默认 1000，负数 = 不限制
+	RetryInterval     time.Duration // 连接建立后通过 retry: 下发的重连间隔，0 = 不下发（默认）
+	RetryJitter       time.Duration // 在 RetryInterval 基础上为每个连接增加 [0, RetryJitter) 的随机抖动，避免重连风暴
+	Logger            Logger        // 日志输出，nil = 以 key=value 文本输出到标准库默认 log.Logger
+	DisableRequestLog bool          // Serve/ServeListener 不记录每个 HTTP 请求
+	// UserIDFunc 从订阅请求中解析用户 ID（如从 Cookie 或 Token 中），用于 SendTo 定向推送。
+	// 未设置时使用 Authenticate 返回的 Principal.ID。
+	UserIDFunc func(r *http.Request) string
+	// Authenticate 校验订阅请求的身份（如 Bearer Token、Cookie），返回错误时以 401 拒绝
+	Authenticate func(r *http.Request) (Principal, error)
+	// Authorize 判断身份是否有权订阅 namespace，返回错误时以 403 拒绝
+	Authorize func(p Principal, namespace string) error
+	// Broker 用于多节点部署时的消息分发，为 nil 时 Broadcast 直接投递到本节点
+	Broker Broker
+	// NodeID 标识本节点，用于生成跨节点唯一的消息 ID，为空时随机生成
+	NodeID string
+	// AdminAuthorize 校验 /admin/ 管理接口的请求，为 nil 时不注册管理接口（默认）。
+	// 不要仅凭来源是否为本机放行：同机部署的反向代理会让所有请求看起来都来自本机。
+	AdminAuthorize      func(r *http.Request) bool
+	SendBufferSize      int                // 每个连接的发送缓冲大小，0 = 默认 256
+	SlowConsumerPolicy  SlowConsumerPolicy // 发送缓冲已满时的处理策略，默认断开连接
+	SlowConsumerTimeout time.Duration      // SlowConsumerBlock 策略的最长等待时间，0 = 默认 1s
+	// MetricsAuthorize 校验 /metrics 的请求，为 nil 时不注册 /metrics（默认）。指标中包含 namespace 名称，不应公开访问。
+	MetricsAuthorize func(r *http.Request) bool
+	// MetricsNamespaceLimit 是 sse_namespace_connections 按连接数输出的 namespace 个数上限，
+	// 其余合计为 namespace="other"；0 = 默认 50，负数表示只输出合计
+	MetricsNamespaceLimit int
+	// PublishSecret 非空时注册 /publish 接口，作为共享密钥或 HMAC 签名密钥
+	PublishSecret string
+	// PublishSignatureMaxAge 签名请求的 X-SSE-Timestamp 与服务器时间允许相差的最大值，0 = 默认 5 分钟
+	PublishSignatureMaxAge time.Duration
+	// EnableSubscriptionAPI 开启后，连接建立时先发送携带连接 ID 的 connected 事件，
+	// 并注册 /subscriptions/{connID} 接口用于动态增删订阅
+	EnableSubscriptionAPI bool
+	// Compression 开启后，按客户端的 Accept-Encoding 以 gzip 或 deflate 压缩事件流
+	Compression bool
+	// CompressionMinSize 连接订阅的 namespace 近期广播消息的平均大小（字节）低于此值时该连接不压缩，
+	// 在连接建立时判断一次，0 = 总是压缩
+	CompressionMinSize int
+	// PresenceEvents 开启后，成员加入或离开 namespace 时向该 namespace 广播 join/leave 事件，
+	// data 为 {"id": "<成员 ID>"}
+	PresenceEvents bool
+	// OnConnect 在连接注册（及 Last-Event-ID 补发）完成后于连接的处理 goroutine 中调用，
+	// send 只向该连接推送消息，可用于发送初始状态快照。回调返回前投递的广播可能先于快照到达。
+	OnConnect func(info ConnectionInfo, send func(SSEMessage) error)
+	// OnDisconnect 在连接注销后调用，reason 为断开原因。被准入控制拒绝的请求同样以
+	// DisconnectLimitExceeded 调用，此时 info.ID 为空。可能在 hub 的 goroutine 中调用，不能阻塞。
+	OnDisconnect func(info ConnectionInfo, reason DisconnectReason)
+	// OnMessageDropped 在消息因队列已满被丢弃时调用：conn 为 nil 表示广播队列已满，消息未投递给任何连接；
+	// 否则为该连接的发送缓冲已满，msg 是实际被丢弃的消息，DropOldest 与 Coalesce 策略下为被挤出缓冲的更早消息。
+	// 补发的历史消息与心跳不经过该回调。
+	// 在投递 goroutine 中同步调用，不能阻塞。
+	OnMessageDropped func(conn *ConnectionInfo, msg SSEMessage)
+}
+
+// Principal 表示通过认证的订阅者身份
+type Principal struct {
+	ID         string
+	Attributes map[string]string
 }
 
 type CorsOptions struct {
@@ -57,20 +158,64 @@ func NewServer(options ...ServerOptions) *Server {
 		stopChan: make(chan struct{}),
 		Debug:    false,
 		Options:  opts,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = NewStdLogger(nil)
 	}
+	s.logger = leveledLogger{Logger: logger, debug: &s.debug}
+	s.hub.logger = s.logger
 
 	if opts.BroadcastWorkers > 0 {
-		s.hub.broadcastWorkers = opts.BroadcastWorkers
+		s.hub.broadcastQueues = newBroadcastQueues(opts.BroadcastWorkers)
+	}
+	if opts.HubShards > 0 {
+		s.hub.shards = newHubShards(opts.HubShards)
+	}
+	if opts.SendBufferSize > 0 {
+		s.hub.sendBufferSize = opts.SendBufferSize
+	}
+	if opts.SlowConsumerTimeout > 0 {
+		s.hub.slowConsumerTimeout = opts.SlowConsumerTimeout
+	}
+	if opts.MaxReplayMessages > 0 {
+		s.hub.maxReplay = opts.MaxReplayMessages
+	} else if opts.MaxReplayMessages < 0 {
+		s.hub.maxReplay = 0
 	}
+	s.hub.slowConsumerPolicy = opts.SlowConsumerPolicy
 	if opts.HeartbeatInterval > 0 {
 		s.heartbeatData = []byte(":keepalive\n\n")
 	}
-	if opts.MaxConnectionsPerIP > 0 {
-		s.ipConns = make(map[string]int32)
+	s.admission = newAdmission(opts)
+	proxies, invalid := parseTrustedProxies(opts.TrustedProxies)
+	s.trustedProxies = proxies
+	for _, entry := range invalid {
+		s.logger.Warn("ignoring invalid trusted proxy", "proxy", entry)
+	}
+	s.hub.presenceEvents = opts.PresenceEvents
+	s.hub.onDisconnect = opts.OnDisconnect
+	s.hub.onMessageDropped = opts.OnMessageDropped
+	if opts.MessageStore != nil {
+		s.hub.store = opts.MessageStore
+	} else if opts.HistorySize > 0 {
+		s.hub.store = newHistory(opts.HistorySize)
+	}
+	if s.hub.store != nil {
+		s.hub.seedID()
 	}
 
-	s.hub.Start(s.Debug)
+	s.hub.Start()
 	s.Broadcast = s.hub.broadcast
+	if opts.Broker != nil {
+		s.nodeID = opts.NodeID
+		if s.nodeID == "" {
+			s.nodeID = newConnectionID()[:8]
+		}
+		s.startBroker()
+	}
 	s.setupRoutes()
 	return s
 }
@@ -81,6 +226,18 @@ func (s *Server) setupRoutes() {
 		http.StripPrefix("/subscribe", s.corsMiddleware(s.connectionHandler())),
 	)
 	s.addHealthCheckEndpoint()
+	if s.Options.MetricsAuthorize != nil {
+		s.addMetricsEndpoint()
+	}
+	if s.Options.AdminAuthorize != nil && !s.Options.DisableAdminEndpoints {
+		s.addAdminEndpoints()
+	}
+	if s.Options.PublishSecret != "" {
+		s.addPublishEndpoint()
+	}
+	if s.Options.EnableSubscriptionAPI {
+		s.addSubscriptionEndpoint()
+	}
 }
 
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
@@ -102,7 +259,7 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 
 func (s *Server) setDefaultCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 }
 
@@ -132,65 +289,102 @@ func (s *Server) setCustomCORSHeaders(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) connectionHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		s.logDebug("New SSE connection established from %s", r.RemoteAddr)
-		defer s.logDebug("SSE connection closed for %s", r.RemoteAddr)
-
-		// Per-IP 连接限制
-		if s.ipConns != nil {
-			ip := extractIP(r.RemoteAddr)
-			s.ipConnsMu.Lock()
-			if s.ipConns[ip] >= int32(s.Options.MaxConnectionsPerIP) {
-				s.ipConnsMu.Unlock()
-				http.Error(w, "Too many connections", http.StatusTooManyRequests)
-				return
-			}
-			s.ipConns[ip]++
-			s.ipConnsMu.Unlock()
-			defer func() {
-				s.ipConnsMu.Lock()
-				s.ipConns[ip]--
-				if s.ipConns[ip] <= 0 {
-					delete(s.ipConns, ip)
-				}
-				s.ipConnsMu.Unlock()
-			}()
+		ip := s.clientIP(r)
+
+		namespaces, err := subscribeNamespaces(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter, err := parseConnFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		principal, status := s.authenticate(r, namespaces)
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		userID := principal.ID
+		if s.Options.UserIDFunc != nil {
+			userID = s.Options.UserIDFunc(r)
+		}
+		identity := principal.ID
+		if identity == "" {
+			identity = userID
 		}
 
+		// 准入控制在发送响应头之前完成，被拒绝的客户端收到 429/503 与 Retry-After
+		slot, rejected := s.admit(ip, identity, namespaces)
+		if rejected != nil {
+			s.logger.Debug("connection rejected", "ip", ip, "namespace", namespaces, "reason", rejectReasonNames[rejected.reason])
+			rejected.write(w)
+			s.notifyRejected(ip, userID, namespaces)
+			return
+		}
+		defer slot.release()
+
 		// 设置 headers
 		flusher, ok := w.(http.Flusher)
 		if !ok {
 			http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
 			return
 		}
+		// 创建新连接，连接 ID 通过响应头返回给客户端
+		conn := s.hub.newConnection()
+		conn.namespaces = namespaces
+		conn.lastEventID = lastEventID(r)
+		conn.principal = principal
+		conn.remoteIP = ip
+		conn.filter = filter
+		conn.userID = userID
+		conn.admission = slot
+		s.logger.Debug("connection opened", "conn", conn.id, "ip", ip, "namespace", namespaces, "user", userID)
+
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-SSE-Connection-ID", conn.id)
+		var out io.Writer = w
+		cw := s.newCompressWriter(w, r, flusher, namespaces)
 		flusher.Flush() // 立即发送 headers，避免客户端等待首条消息才收到响应头
+		if cw != nil {
+			defer cw.Close()
+			out, flusher = cw, cw
+		}
 
-		// 创建并注册新连接
-		conn := s.hub.newConnection()
-		sendCh := conn.send
+		if retry := s.retryInterval(); retry > 0 {
+			if _, err := out.Write(append(appendRetry(nil, retry), '\n')); err != nil {
+				return
+			}
+			s.safeFlush(flusher)
+		}
+		if s.Options.EnableSubscriptionAPI {
+			if err := s.writeFrame(out, flusher, connectedFrame(conn.id)); err != nil {
+				return
+			}
+		}
 
-		select {
-		case s.hub.register <- conn:
-		default:
-			go func() {
-				select {
-				case s.hub.register <- conn:
-				case <-s.stopChan:
-					conn.safeClose()
-					s.hub.putConnection(conn)
-				}
-			}()
+		// 注册新连接。分片化后注册只锁连接所在的分片，直接在处理 goroutine 中完成，
+		// 使 OnConnect 调用时连接已能收到广播
+		sendCh := conn.send
+		if !s.hub.registerConnection(conn) {
+			conn.safeClose()
+			return
 		}
 		defer func() {
+			// hub 主动断开时已记录原因，不会被覆盖
+			s.logger.Debug("connection closed", "conn", conn.id, "ip", ip)
+			conn.setCloseReason(DisconnectClientClosed)
 			select {
 			case s.hub.unregister <- conn:
 			default:
 				s.hub.unregisterConnection(conn)
 			}
-			s.logDebug("Connection closed for %s", r.RemoteAddr)
 		}()
+		s.notifyConnect(conn)
 
 		ctx := r.Context()
 
@@ -210,45 +404,36 @@ func (s *Server) connectionHandler() http.Handler {
 
 		for {
 			select {
-			case msg, ok := <-sendCh:
+			case f, ok := <-sendCh:
 				if !ok {
 					return
 				}
 				select {
 				case <-ctx.Done():
+					f.release()
 					return
 				default:
 				}
 
 				conn.updateActivity()
-				if _, err := w.Write(msg); err != nil {
-					s.logError("Error writing to client: %v", err)
-					return
-				}
-
-				select {
-				case <-ctx.Done():
+				if err := s.writeBatch(out, flusher, f, sendCh); err != nil {
+					s.logger.Warn("write to client failed", "conn", conn.id, "ip", ip, "err", err)
 					return
-				default:
 				}
-
-				s.safeFlush(flusher)
 			case <-heartbeatC:
 				select {
 				case <-ctx.Done():
 					return
 				default:
 				}
-				if _, err := w.Write(s.heartbeatData); err != nil {
+				if err := s.writeFrame(out, flusher, s.heartbeatData); err != nil {
 					return
 				}
-				s.safeFlush(flusher)
 				conn.updateActivity()
 			case <-idleTicker.C:
-				if _, err := w.Write([]byte(":\n\n")); err != nil {
+				if err := s.writeFrame(out, flusher, idleFrame); err != nil {
 					return
 				}
-				s.safeFlush(flusher)
 			case <-ctx.Done():
 				return
 			}
@@ -256,11 +441,104 @@ func (s *Server) connectionHandler() http.Handler {
 	})
 }
 
+// authenticate 执行认证与授权钩子，返回订阅者身份及 HTTP 状态码
+func (s *Server) authenticate(r *http.Request, namespaces []string) (Principal, int) {
+	var principal Principal
+	if s.Options.Authenticate != nil {
+		p, err := s.Options.Authenticate(r)
+		if err != nil {
+			s.logger.Debug("authentication failed", "ip", s.clientIP(r), "err", err)
+			return principal, http.StatusUnauthorized
+		}
+		principal = p
+	}
+	if !s.authorize(principal, namespaces) {
+		return principal, http.StatusForbidden
+	}
+	return principal, http.StatusOK
+}
+
+// authorize 检查身份能否订阅全部 namespace
+func (s *Server) authorize(principal Principal, namespaces []string) bool {
+	if s.Options.Authorize == nil {
+		return true
+	}
+	for _, ns := range namespaces {
+		if err := s.Options.Authorize(principal, ns); err != nil {
+			s.logger.Debug("principal not authorized", "principal", principal.ID, "namespace", ns, "err", err)
+			return false
+		}
+	}
+	return true
+}
+
+// retryInterval 返回本连接的重连间隔，包含随机抖动
+func (s *Server) retryInterval() time.Duration {
+	retry := s.Options.RetryInterval
+	if retry <= 0 {
+		return 0
+	}
+	if s.Options.RetryJitter > 0 {
+		s.rngMu.Lock()
+		retry += time.Duration(s.rng.Int63n(int64(s.Options.RetryJitter)))
+		s.rngMu.Unlock()
+	}
+	return retry
+}
+
+var idleFrame = []byte(":\n\n")
+
+// writeFrame 写入一帧并立即 flush，同时记录写入字节数与延迟
+func (s *Server) writeFrame(w io.Writer, flusher http.Flusher, data []byte) error {
+	start := time.Now()
+	n, err := w.Write(data)
+	atomic.AddUint64(&s.hub.metrics.bytesWritten, uint64(n))
+	if err != nil {
+		return err
+	}
+	s.safeFlush(flusher)
+	s.hub.metrics.writeLatency.observe(time.Since(start))
+	return nil
+}
+
+// writeBatch 写出 first 及发送缓冲中已排队的帧。有排队的帧时合并到一个缓冲中，
+// 只调用一次 Write 与 Flush，积压时减少系统调用次数。写出后释放各帧的引用。
+func (s *Server) writeBatch(w io.Writer, flusher http.Flusher, first *frame, queue <-chan *frame) error {
+	if len(queue) == 0 {
+		err := s.writeFrame(w, flusher, first.buf)
+		first.release()
+		return err
+	}
+
+	bp := batchPool.Get().(*[]byte)
+	batch := append((*bp)[:0], first.buf...)
+	first.release()
+	for n := 1; n < maxBatchFrames && len(batch) < maxBatchSize; n++ {
+		var f *frame
+		select {
+		case f = <-queue:
+		default:
+		}
+		// 通道已关闭或暂无排队的帧
+		if f == nil {
+			break
+		}
+		batch = append(batch, f.buf...)
+		f.release()
+	}
+	err := s.writeFrame(w, flusher, batch)
+	if cap(batch) <= maxBatchSize*2 {
+		*bp = batch[:0]
+		batchPool.Put(bp)
+	}
+	return err
+}
+
 // safeFlush 安全地调用 Flush，捕获可能的 panic 防止段错误导致程序崩溃
 func (s *Server) safeFlush(flusher http.Flusher) {
 	defer func() {
 		if r := recover(); r != nil {
-			s.logError("Recovered from panic in Flush: %v", r)
+			s.logger.Error("recovered from panic in Flush", "panic", r)
 		}
 	}()
 	flusher.Flush()
@@ -270,21 +548,34 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
 }
 
+// SetDebug 开启或关闭 Debug 级别的日志，可在服务运行期间并发调用
+func (s *Server) SetDebug(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&s.debug, v)
+}
+
 func (s *Server) Serve(addr string) error {
-	log.Println("Starting server on addr " + addr)
-	handler := s.proxyRemoteAddrHandler(s.requestLogger(http.HandlerFunc(s.ServeHTTP)))
+	if s.Debug {
+		s.SetDebug(true)
+	}
+	s.logger.Info("starting server", "addr", addr)
 	s.server = &http.Server{
 		Addr:    addr,
-		Handler: handler,
+		Handler: s.requestLogger(s),
 	}
 	return s.server.ListenAndServe()
 }
 
 func (s *Server) ServeListener(listener net.Listener) error {
-	log.Println("Starting server on " + listener.Addr().String())
-	handler := s.proxyRemoteAddrHandler(s.requestLogger(http.HandlerFunc(s.ServeHTTP)))
+	if s.Debug {
+		s.SetDebug(true)
+	}
+	s.logger.Info("starting server", "addr", listener.Addr().String())
 	s.server = &http.Server{
-		Handler: handler,
+		Handler: s.requestLogger(s),
 	}
 	return s.server.Serve(listener)
 }
@@ -306,25 +597,41 @@ func (s *Server) Stop() error {
 	return nil
 }
 
-func (s *Server) proxyRemoteAddrHandler(next http.Handler) http.Handler {
+// requestLogger 以 Info 级别记录每个请求，DisableRequestLog 时直接返回 next
+func (s *Server) requestLogger(next http.Handler) http.Handler {
+	if s.Options.DisableRequestLog {
+		return next
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.Header.Get("X-Real-IP")
-		if ip == "" {
-			ip = r.Header.Get("X-Forwarded-For")
-			if ip == "" {
-				ip = r.RemoteAddr
-			}
-		}
-		r.RemoteAddr = ip
+		s.logger.Info("http request", "ip", s.clientIP(r), "method", r.Method, "url", r.URL.String())
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (s *Server) requestLogger(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL)
-		next.ServeHTTP(w, r)
-	})
+// Publish 同步广播一条消息。与 Broadcast 通道不同，广播队列已满时立即返回 ErrQueueFull
+// 而不是阻塞或静默丢弃；否则等待投递完成并返回各连接的投递结果，ctx 结束时返回 ctx.Err()。
+// 配置了 Broker 时消息经 Broker 分发，结果为空，error 仅反映 Broker 是否接收；
+// ctx 同样约束发布到 Broker 的过程，见 ContextPublisher。
+func (s *Server) Publish(ctx context.Context, msg SSEMessage) (PublishResult, error) {
+	if err := ctx.Err(); err != nil {
+		return PublishResult{}, err
+	}
+	if s.Options.Broker != nil {
+		return PublishResult{}, s.publishToBroker(ctx, msg)
+	}
+	return s.hub.publish(ctx, msg)
+}
+
+// SendTo 向指定用户的所有连接推送消息，返回成功送达的连接数。
+// 定向消息不会记入历史，也不会自动分配 ID。
+func (s *Server) SendTo(userID string, msg SSEMessage) int {
+	return s.hub.sendToUser(userID, msg)
+}
+
+// SendToConnection 向指定 ID 的连接推送消息。
+// 连接不存在时返回 ErrConnectionNotFound，发送缓冲已满时返回 ErrSendBufferFull 并断开该连接。
+func (s *Server) SendToConnection(id string, msg SSEMessage) error {
+	return s.hub.sendToConnection(id, msg)
 }
 
 func (s *Server) GetActiveConnectionCount() int32 {
@@ -343,14 +650,13 @@ func (s *Server) addHealthCheckEndpoint() {
 	})
 }
 
-func (s *Server) logError(format string, v ...any) {
-	log.Printf(format, v...)
-}
-
-func (s *Server) logDebug(format string, v ...any) {
-	if s.Debug {
-		log.Printf(format, v...)
+// lastEventID 读取客户端最后收到的事件 ID。EventSource 重连时会自动携带
+// Last-Event-ID 请求头；首次连接无法设置请求头，可改用 lastEventId 查询参数。
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
 	}
+	return r.URL.Query().Get("lastEventId")
 }
 
 func extractIP(remoteAddr string) string {
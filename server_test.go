@@ -3,8 +3,10 @@ package sseserver
 import (
 	"bufio"
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -41,7 +43,7 @@ func TestConnectionHandler_Headers(t *testing.T) {
 
 func TestServeHTTP(t *testing.T) {
 	server := NewServer()
-	server.Debug = true
+	server.SetDebug(true)
 
 	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
 	defer ts.Close()
@@ -83,7 +85,7 @@ func TestServeHTTP(t *testing.T) {
 
 func TestBroadcast(t *testing.T) {
 	server := NewServer()
-	server.Debug = true
+	server.SetDebug(true)
 
 	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
 	defer ts.Close()
@@ -186,3 +188,183 @@ func TestBroadcastAfterReconnect(t *testing.T) {
 		t.Fatalf("重连后广播失效，响应: %s", got)
 	}
 }
+
+func TestSubscribeNamespace(t *testing.T) {
+	server := NewServer()
+
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/subscribe/sysenv/update", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	waitUntil(t, time.Second, func() bool {
+		return server.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	server.Broadcast <- SSEMessage{Event: "skip", Data: []byte("other"), Namespace: "/other"}
+	server.Broadcast <- SSEMessage{Event: "env", Data: []byte("ok"), Namespace: "/sysenv/update"}
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for i := 0; i < 4; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取响应失败: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	got := strings.Join(lines, "")
+	expected := "event:env\nnamespace:/sysenv/update\ndata:ok\n\n"
+	if got != expected {
+		t.Errorf("namespace 路由错误。得到: %q, 想要: %q", got, expected)
+	}
+}
+
+func TestLastEventIDReplay(t *testing.T) {
+	server := NewServer(ServerOptions{HistorySize: 16})
+
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	// 没有客户端时发送的消息只进入历史
+	for i := 0; i < 3; i++ {
+		server.Broadcast <- SSEMessage{Event: "tick", Data: []byte(strconv.Itoa(i))}
+	}
+	waitUntil(t, time.Second, func() bool {
+		return len(server.hub.store.(*history).since([]string{""}, "", "")) == 3
+	}, "消息未记入历史")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/subscribe/", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	waitUntil(t, time.Second, func() bool {
+		return server.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+	server.Broadcast <- SSEMessage{Event: "tick", Data: []byte("live")}
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for i := 0; i < 12; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取响应失败: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	got := strings.Join(lines, "")
+	expected := "id:2\nevent:tick\ndata:1\n\n" +
+		"id:3\nevent:tick\ndata:2\n\n" +
+		"id:4\nevent:tick\ndata:live\n\n"
+	if got != expected {
+		t.Errorf("补发消息错误。得到: %q, 想要: %q", got, expected)
+	}
+}
+
+func TestRetryHint(t *testing.T) {
+	server := NewServer(ServerOptions{
+		RetryInterval: 2 * time.Second,
+		RetryJitter:   time.Second,
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/subscribe/", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("读取响应失败: %v", err)
+	}
+	if !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("首行应为 retry:，得到 %q", line)
+	}
+	ms, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "retry:")))
+	if err != nil {
+		t.Fatalf("retry 值无法解析: %q", line)
+	}
+	if ms < 2000 || ms >= 3000 {
+		t.Errorf("retry 超出抖动范围，得到 %d", ms)
+	}
+}
+
+func TestAuthHooks(t *testing.T) {
+	server := NewServer(ServerOptions{
+		Authenticate: func(r *http.Request) (Principal, error) {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" {
+				return Principal{}, errors.New("missing token")
+			}
+			return Principal{ID: token}, nil
+		},
+		Authorize: func(p Principal, namespace string) error {
+			if namespace == "/admin" && p.ID != "root" {
+				return errors.New("forbidden")
+			}
+			return nil
+		},
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	do := func(path, token string) *http.Response {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+		req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := do("/subscribe/", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("未认证请求状态码错误，得到 %d，想要 401", resp.StatusCode)
+	}
+	if resp := do("/subscribe/admin", "alice"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("未授权请求状态码错误，得到 %d，想要 403", resp.StatusCode)
+	}
+	resp := do("/subscribe/admin", "root")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("合法请求状态码错误，得到 %d", resp.StatusCode)
+	}
+
+	waitUntil(t, time.Second, func() bool {
+		return server.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	// Principal.ID 作为用户 ID 用于定向推送
+	if n := server.SendTo("root", SSEMessage{Event: "hello", Data: []byte("root")}); n != 1 {
+		t.Errorf("SendTo 送达数错误，得到 %d，想要 1", n)
+	}
+}
@@ -0,0 +1,240 @@
+package sseserver
+
+import "sync"
+
+const (
+	defaultHubShards   = 16
+	shardQueueCapacity = 1024
+)
+
+// hubShard 持有按连接 ID 哈希分配到的一部分连接及其索引。每个分片有独立的锁与投递 worker：
+// 广播在各分片上并行投递，某个分片的注册注销只锁该分片，不会阻塞其它分片。
+type hubShard struct {
+	mu              sync.RWMutex
+	connections     map[*connection]bool
+	subscriptions   *nsTrie              // 按订阅 namespace 索引的连接
+	multiSubscribed map[*connection]bool // 订阅了多个 namespace 的连接，广播时需去重
+	byID            map[string]*connection
+	byUser          map[string]map[*connection]bool
+	queue           chan *shardJob
+}
+
+func newHubShard() *hubShard {
+	return &hubShard{
+		connections:     make(map[*connection]bool),
+		subscriptions:   newNSTrie(),
+		multiSubscribed: make(map[*connection]bool),
+		byID:            make(map[string]*connection),
+		byUser:          make(map[string]map[*connection]bool),
+		queue:           make(chan *shardJob, shardQueueCapacity),
+	}
+}
+
+func newHubShards(n int) []*hubShard {
+	shards := make([]*hubShard, n)
+	for i := range shards {
+		shards[i] = newHubShard()
+	}
+	return shards
+}
+
+// shardFor 返回连接 ID 所在的分片
+func (h *hub) shardFor(id string) *hubShard {
+	if len(h.shards) == 1 {
+		return h.shards[0]
+	}
+	return h.shards[fnv32a(id)%uint32(len(h.shards))]
+}
+
+// fnv32a 计算字符串的 FNV-1a 哈希，避免转换为 []byte 的分配
+func fnv32a(s string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint32(s[i])
+		hash *= 16777619
+	}
+	return hash
+}
+
+// add 将连接加入分片的索引，调用方需持有写锁
+func (sh *hubShard) add(conn *connection) {
+	sh.connections[conn] = true
+	for _, ns := range conn.namespaces {
+		sh.subscriptions.add(ns, conn)
+	}
+	sh.updateMultiSubscription(conn)
+	sh.byID[conn.id] = conn
+	if conn.userID != "" {
+		userConns := sh.byUser[conn.userID]
+		if userConns == nil {
+			userConns = make(map[*connection]bool)
+			sh.byUser[conn.userID] = userConns
+		}
+		userConns[conn] = true
+	}
+}
+
+// remove 将连接从分片的索引中移除，返回连接是否存在，调用方需持有写锁
+func (sh *hubShard) remove(conn *connection) bool {
+	if !sh.connections[conn] {
+		return false
+	}
+	delete(sh.connections, conn)
+	for _, ns := range conn.namespaces {
+		sh.subscriptions.remove(ns, conn)
+	}
+	delete(sh.multiSubscribed, conn)
+	delete(sh.byID, conn.id)
+	if userConns := sh.byUser[conn.userID]; userConns != nil {
+		delete(userConns, conn)
+		if len(userConns) == 0 {
+			delete(sh.byUser, conn.userID)
+		}
+	}
+	return true
+}
+
+// updateMultiSubscription 根据连接当前订阅数更新 multiSubscribed，调用方需持有写锁
+func (sh *hubShard) updateMultiSubscription(conn *connection) {
+	if len(conn.namespaces) > 1 {
+		sh.multiSubscribed[conn] = true
+	} else {
+		delete(sh.multiSubscribed, conn)
+	}
+}
+
+// appendSubscribers 将应收到 namespace 消息的连接追加到 conns，调用方需持有读锁。
+// 空 namespace 的消息发给所有连接；其它消息只发给订阅 pattern 与之匹配的连接，
+// 通过前缀树查找，避免遍历全部连接。
+func (sh *hubShard) appendSubscribers(conns []*connection, namespace string) []*connection {
+	if namespace == "" {
+		for conn := range sh.connections {
+			conns = append(conns, conn)
+		}
+		return conns
+	}
+	if len(sh.multiSubscribed) == 0 {
+		return sh.subscriptions.appendMatches(conns, namespace)
+	}
+	start := len(conns)
+	conns = sh.subscriptions.appendMatches(conns, namespace)
+	return dedupeConnections(conns, start)
+}
+
+// dedupeConnections 去除 conns[start:] 中的重复连接，一个连接的多个订阅可能同时匹配同一条消息
+func dedupeConnections(conns []*connection, start int) []*connection {
+	if len(conns)-start < 2 {
+		return conns
+	}
+	seen := make(map[*connection]struct{}, len(conns)-start)
+	out := conns[:start]
+	for _, conn := range conns[start:] {
+		if _, ok := seen[conn]; !ok {
+			seen[conn] = struct{}{}
+			out = append(out, conn)
+		}
+	}
+	for i := len(out); i < len(conns); i++ {
+		conns[i] = nil
+	}
+	return out
+}
+
+// shardJob 是分发到各分片的一条广播，所有分片共享同一个编码后的帧，
+// 最后一个完成的分片释放帧并回传汇总的投递结果
+type shardJob struct {
+	msg     SSEMessage
+	frame   *frame
+	seq     uint64 // 写入消息存储时的序号，用于跳过补发过的消息，0 表示未写入
+	payload lazyJSON
+
+	mu      sync.Mutex
+	pending int
+	result  PublishResult
+	done    chan PublishResult
+}
+
+func (j *shardJob) finish(r PublishResult) {
+	j.mu.Lock()
+	j.result.Accepted += r.Accepted
+	j.result.Skipped += r.Skipped
+	j.result.Dropped += r.Dropped
+	j.pending--
+	last := j.pending == 0
+	result := j.result
+	j.mu.Unlock()
+	if last {
+		j.frame.release()
+		if j.done != nil {
+			j.done <- result
+		}
+	}
+}
+
+// shardWorker 按顺序投递分片队列中的广播
+func (h *hub) shardWorker(sh *hubShard) {
+	for {
+		select {
+		case job := <-sh.queue:
+			job.finish(h.deliverShard(sh, job))
+		case <-h.stopChan:
+			return
+		}
+	}
+}
+
+// deliverShard 向分片内订阅了消息 namespace 的连接投递
+func (h *hub) deliverShard(sh *hubShard, job *shardJob) PublishResult {
+	connsPtr := h.slicePool.Get().(*[]*connection)
+	conns := (*connsPtr)[:0]
+
+	sh.mu.RLock()
+	conns = sh.appendSubscribers(conns, normalizeNamespace(job.msg.Namespace))
+	sh.mu.RUnlock()
+
+	var result PublishResult
+	// 投递失败的连接原地移到 conns 前部，避免额外分配
+	failed := 0
+	for _, conn := range conns {
+		// 注册时已从消息存储补发过的消息
+		if job.seq != 0 && job.seq <= conn.replayedSeq {
+			result.Skipped++
+			continue
+		}
+		if !conn.filter.match(&job.msg, &job.payload) {
+			result.Skipped++
+			continue
+		}
+		switch h.deliver(conn, job.frame, &job.msg) {
+		case deliveryOK, deliveryEvicted:
+			result.Accepted++
+		case deliverySkipped:
+			result.Skipped++
+		case deliveryDropped:
+			result.Dropped++
+		case deliveryFailed:
+			result.Dropped++
+			conns[failed] = conn
+			failed++
+		}
+	}
+
+	h.dropConnections(conns[:failed], DisconnectSlowConsumer)
+	for i := range conns {
+		conns[i] = nil
+	}
+	*connsPtr = conns[:0]
+	h.slicePool.Put(connsPtr)
+	return result
+}
+
+// eachShardConnection 依次对每个分片中的连接调用 fn，持有分片读锁
+func (h *hub) eachShardConnection(fn func(conn *connection)) {
+	for _, sh := range h.shards {
+		sh.mu.RLock()
+		for conn := range sh.connections {
+			fn(conn)
+		}
+		sh.mu.RUnlock()
+	}
+}
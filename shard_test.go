@@ -0,0 +1,142 @@
+package sseserver
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHubShardsDistribute(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+	var conns []*connection
+	for i := 0; i < 200; i++ {
+		conn := h.newConnection()
+		conn.userID = "u" + strconv.Itoa(i%2)
+		h.registerConnection(conn)
+		conns = append(conns, conn)
+	}
+
+	used := 0
+	for _, sh := range h.shards {
+		if len(sh.connections) > 0 {
+			used++
+		}
+	}
+	if used < len(h.shards)/2 {
+		t.Errorf("连接未分散到各分片，只使用了 %d 个分片", used)
+	}
+	for _, conn := range conns {
+		if h.connectionByID(conn.id) != conn {
+			t.Fatalf("按 ID 查找连接失败")
+		}
+	}
+
+	if result := publishSync(t, h, SSEMessage{Data: []byte("all")}); result.Accepted != 200 {
+		t.Errorf("广播应投递到全部分片的连接，得到 %+v", result)
+	}
+	if sent := h.sendToUser("u1", SSEMessage{Data: []byte("u1")}); sent != 100 {
+		t.Errorf("定向推送应覆盖全部分片，得到 %d", sent)
+	}
+	if counts := h.namespaceCounts(); len(counts) != 1 || counts[0].count != 200 {
+		t.Errorf("各分片的 namespace 计数未合并: %+v", counts)
+	}
+}
+
+func TestShardSkipsReplayedMessages(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+	h.store = newHistory(16)
+	for i := 1; i <= 3; i++ {
+		msg := SSEMessage{Data: []byte(strconv.Itoa(i))}
+		h.assignID(&msg)
+		publishSync(t, h, msg)
+	}
+
+	conn := h.newConnection()
+	conn.lastEventID = "1"
+	h.registerConnection(conn)
+	if got := drainFrames(conn); len(got) != 1 || got[0] != "id:2\ndata:2\n\nid:3\ndata:3\n\n" {
+		t.Fatalf("补发内容错误: %q", got)
+	}
+
+	// 写入存储早于补发读取、但尚未投递到分片的消息不应再次投递
+	job := &shardJob{msg: SSEMessage{ID: "3"}, frame: newFrame([]byte("id:3\ndata:3\n\n")), seq: 3}
+	if result := h.deliverShard(h.shardFor(conn.id), job); result.Accepted != 0 {
+		t.Errorf("已补发的消息被重复投递: %+v", result)
+	}
+	publishSync(t, h, SSEMessage{ID: "4", Data: []byte("4")})
+	if got := drainFrames(conn); len(got) != 1 || got[0] != "id:4\ndata:4\n\n" {
+		t.Errorf("补发之后的消息应正常投递: %q", got)
+	}
+}
+
+// 补发只到注册时存储中的最后一条为止，超过 maxReplay 时只补发最近的部分
+func TestReplayBoundedBySnapshotAndLimit(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+	h.store = newHistory(16)
+	h.maxReplay = 2
+	for i := 1; i <= 4; i++ {
+		msg := SSEMessage{Data: []byte(strconv.Itoa(i))}
+		h.assignID(&msg)
+		publishSync(t, h, msg)
+	}
+	// 注册之后才写入存储的消息由实时投递送达，补发不应包含
+	h.store.Append(SSEMessage{ID: "5", Data: []byte("5")})
+
+	conn := h.newConnection()
+	conn.lastEventID = "unknown"
+	h.registerConnection(conn)
+	if got := drainFrames(conn); len(got) != 1 || got[0] != "id:3\ndata:3\n\nid:4\ndata:4\n\n" {
+		t.Errorf("补发内容错误: %q", got)
+	}
+}
+
+// 补发期间投递的帧暂存，补发的历史消息先于它们发送
+func TestReplayDefersLiveFrames(t *testing.T) {
+	h := newHub()
+	conn := h.newConnection()
+	conn.replaying = true
+	live := newFrame([]byte("id:9\ndata:live\n\n"))
+	defer live.release()
+	if r, _ := conn.deliver(live, SlowConsumerDisconnect, 0); r != deliveryOK {
+		t.Fatalf("补发期间投递结果为 %v", r)
+	}
+	if len(conn.send) != 0 {
+		t.Fatal("补发完成前不应放入发送缓冲")
+	}
+	conn.finishReplay([]byte("id:8\ndata:old\n\n"))
+	if got := drainFrames(conn); len(got) != 2 || got[0] != "id:8\ndata:old\n\n" || got[1] != "id:9\ndata:live\n\n" {
+		t.Errorf("补发与实时消息顺序错误: %q", got)
+	}
+}
+
+// 分片 worker 在注销后仍可能持有连接指针，向旧连接投递不能落到新连接上
+func TestStaleConnectionAfterUnregister(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+
+	old := h.newConnection()
+	h.registerConnection(old)
+	h.unregisterConnection(old)
+
+	fresh := h.newConnection()
+	h.registerConnection(fresh)
+	defer h.unregisterConnection(fresh)
+	if fresh == old {
+		t.Fatal("注销的连接被复用")
+	}
+
+	f := encodeFrame(&SSEMessage{Data: []byte("stale")})
+	defer f.release()
+	if r, _ := old.deliver(f, SlowConsumerDropOldest, 0); r != deliverySkipped {
+		t.Errorf("向已注销的连接投递结果为 %v，期望跳过", r)
+	}
+	if n := len(fresh.send); n != 0 {
+		t.Errorf("新连接收到 %d 帧发给旧连接的数据", n)
+	}
+}
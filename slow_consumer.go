@@ -0,0 +1,180 @@
+package sseserver
+
+import (
+	"bytes"
+	"time"
+)
+
+// SlowConsumerPolicy 决定连接发送缓冲已满时如何处理新消息
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerDisconnect 断开连接，由客户端重连（默认）
+	SlowConsumerDisconnect SlowConsumerPolicy = iota
+	// SlowConsumerDropOldest 丢弃缓冲中最旧的消息，为新消息腾出位置
+	SlowConsumerDropOldest
+	// SlowConsumerDropNewest 丢弃新消息，保留缓冲中已有的消息
+	SlowConsumerDropNewest
+	// SlowConsumerCoalesce 用新消息替换缓冲中 event 与 namespace 相同的旧消息，
+	// 没有可替换的消息时退化为 SlowConsumerDropOldest
+	SlowConsumerCoalesce
+	// SlowConsumerBlock 阻塞等待缓冲腾出空间，超过 SlowConsumerTimeout 后断开连接。
+	// 阻塞期间会拖慢同一 worker 上其它连接的投递。
+	SlowConsumerBlock
+)
+
+const (
+	defaultSendBufferSize      = 256
+	defaultSlowConsumerTimeout = time.Second
+)
+
+type deliveryResult int
+
+const (
+	deliveryOK      deliveryResult = iota
+	deliverySkipped                // 连接已关闭
+	deliveryEvicted                // 消息已放入缓冲，挤出了缓冲中更早的一条
+	deliveryDropped                // 消息被丢弃，连接保留
+	deliveryFailed                 // 应断开连接
+)
+
+// deliver 按慢消费者策略投递一帧数据。持有 mutex 期间完成发送，与 safeClose 互斥。
+// 放入发送缓冲的帧持有一次引用，被丢弃或替换的帧释放引用。
+// 结果为 deliveryEvicted 时同时返回被挤出的帧所编码的消息，该帧不来自消息时为 nil。
+func (c *connection) deliver(f *frame, policy SlowConsumerPolicy, timeout time.Duration) (deliveryResult, *SSEMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return deliverySkipped, nil
+	}
+	if c.replaying {
+		return c.deferDelivery(f, policy), nil
+	}
+	f.retain()
+	select {
+	case c.send <- f:
+		return deliveryOK, nil
+	default:
+	}
+
+	switch policy {
+	case SlowConsumerDropNewest:
+		f.release()
+		return deliveryDropped, nil
+	case SlowConsumerDropOldest:
+		return deliveryEvicted, c.dropOldest(f)
+	case SlowConsumerCoalesce:
+		if evicted, ok := c.coalesce(f); ok {
+			return deliveryEvicted, evicted
+		}
+		return deliveryEvicted, c.dropOldest(f)
+	case SlowConsumerBlock:
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case c.send <- f:
+			return deliveryOK, nil
+		case <-timer.C:
+			f.release()
+			return deliveryFailed, nil
+		}
+	default:
+		f.release()
+		return deliveryFailed, nil
+	}
+}
+
+// deferDelivery 在补发期间暂存帧，调用方需持有 mutex。暂存数量以发送缓冲容量为限（为补发的帧留出一个位置），
+// 超出时按缓冲已满处理：Disconnect 与 Block 策略断开连接，其它策略丢弃新帧
+func (c *connection) deferDelivery(f *frame, policy SlowConsumerPolicy) deliveryResult {
+	if len(c.pending) >= cap(c.send)-1 {
+		if policy == SlowConsumerDisconnect || policy == SlowConsumerBlock {
+			return deliveryFailed
+		}
+		return deliveryDropped
+	}
+	f.retain()
+	c.pending = append(c.pending, f)
+	return deliveryOK
+}
+
+// dropOldest 丢弃最旧的一帧后放入新帧并返回被丢弃帧的消息，调用方需持有 mutex
+func (c *connection) dropOldest(f *frame) *SSEMessage {
+	var evicted *SSEMessage
+	select {
+	case old := <-c.send:
+		evicted = old.msg
+		old.release()
+	default:
+	}
+	select {
+	case c.send <- f:
+	default:
+		f.release()
+	}
+	return evicted
+}
+
+// coalesce 移除缓冲中与 f 键相同的旧帧并把 f 放到队尾，返回被替换帧的消息，调用方需持有 mutex。
+// 没有相同键的旧帧时不做任何修改并返回 false。
+func (c *connection) coalesce(f *frame) (*SSEMessage, bool) {
+	key := frameKey(f.buf)
+	if key == "" {
+		return nil, false
+	}
+	pending := make([]*frame, 0, len(c.send))
+drain:
+	for {
+		select {
+		case queued := <-c.send:
+			pending = append(pending, queued)
+		default:
+			break drain
+		}
+	}
+
+	var evicted *SSEMessage
+	replaced := false
+	for i, queued := range pending {
+		if frameKey(queued.buf) == key {
+			evicted = queued.msg
+			queued.release()
+			pending = append(pending[:i], pending[i+1:]...)
+			pending = append(pending, f)
+			replaced = true
+			break
+		}
+	}
+	for _, queued := range pending {
+		select {
+		case c.send <- queued:
+		default:
+			queued.release()
+		}
+	}
+	return evicted, replaced
+}
+
+// frameKey 从编码后的帧中提取 event 与 namespace 作为合并键，没有 event 的帧返回空字符串
+func frameKey(frame []byte) string {
+	var event, namespace []byte
+	for len(frame) > 0 {
+		i := bytes.IndexByte(frame, '\n')
+		if i <= 0 {
+			break
+		}
+		line := frame[:i]
+		frame = frame[i+1:]
+		if bytes.HasPrefix(line, []byte("event:")) {
+			event = line[6:]
+		} else if bytes.HasPrefix(line, []byte("namespace:")) {
+			namespace = line[10:]
+		} else if bytes.HasPrefix(line, []byte("data:")) {
+			break
+		}
+	}
+	if len(event) == 0 {
+		return ""
+	}
+	return string(event) + "\x00" + string(namespace)
+}
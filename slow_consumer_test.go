@@ -0,0 +1,113 @@
+package sseserver
+
+import (
+	"testing"
+	"time"
+)
+
+func drainFrames(conn *connection) []string {
+	var frames []string
+	for {
+		select {
+		case frame := <-conn.send:
+			frames = append(frames, string(frame.buf))
+		default:
+			return frames
+		}
+	}
+}
+
+func TestSlowConsumerPolicies(t *testing.T) {
+	msgA := SSEMessage{Event: "status", Data: []byte("a")}
+	msgB := SSEMessage{Event: "alarm", Data: []byte("b")}
+	a, b := msgA.Bytes(), msgB.Bytes()
+	c := SSEMessage{Event: "status", Data: []byte("c")}.Bytes()
+	d := SSEMessage{Event: "other", Data: []byte("d")}.Bytes()
+
+	cases := []struct {
+		name    string
+		policy  SlowConsumerPolicy
+		next    []byte
+		result  deliveryResult
+		evicted *SSEMessage
+		want    []string
+	}{
+		{"断开连接", SlowConsumerDisconnect, c, deliveryFailed, nil, []string{string(a), string(b)}},
+		{"丢弃最旧", SlowConsumerDropOldest, c, deliveryEvicted, &msgA, []string{string(b), string(c)}},
+		{"丢弃最新", SlowConsumerDropNewest, c, deliveryDropped, nil, []string{string(a), string(b)}},
+		{"按键合并", SlowConsumerCoalesce, c, deliveryEvicted, &msgA, []string{string(b), string(c)}},
+		{"无可合并时丢弃最旧", SlowConsumerCoalesce, d, deliveryEvicted, &msgA, []string{string(b), string(d)}},
+		{"阻塞超时", SlowConsumerBlock, c, deliveryFailed, nil, []string{string(a), string(b)}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := newHub().newConnection()
+			conn.send = make(chan *frame, 2)
+			conn.deliver(encodeFrame(&msgA), tc.policy, 10*time.Millisecond)
+			conn.deliver(encodeFrame(&msgB), tc.policy, 10*time.Millisecond)
+
+			got, evicted := conn.deliver(newFrame(tc.next), tc.policy, 10*time.Millisecond)
+			if got != tc.result {
+				t.Errorf("投递结果错误，得到 %d，想要 %d", got, tc.result)
+			}
+			if evicted != tc.evicted {
+				t.Errorf("被挤出的消息错误，得到 %+v，想要 %+v", evicted, tc.evicted)
+			}
+			frames := drainFrames(conn)
+			if len(frames) != len(tc.want) {
+				t.Fatalf("缓冲内容错误，得到 %q，想要 %q", frames, tc.want)
+			}
+			for i := range frames {
+				if frames[i] != tc.want[i] {
+					t.Errorf("缓冲内容错误，得到 %q，想要 %q", frames, tc.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSlowConsumerBlockDelivers(t *testing.T) {
+	conn := newHub().newConnection()
+	conn.send = make(chan *frame, 1)
+	conn.send <- newFrame([]byte("old"))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-conn.send
+	}()
+	if got, _ := conn.deliver(newFrame([]byte("new")), SlowConsumerBlock, time.Second); got != deliveryOK {
+		t.Errorf("阻塞策略在缓冲腾出后应投递成功，得到 %d", got)
+	}
+}
+
+func TestSlowConsumerKeepsConnection(t *testing.T) {
+	server := NewServer(ServerOptions{
+		SendBufferSize:     4,
+		SlowConsumerPolicy: SlowConsumerDropOldest,
+	})
+	defer server.Stop()
+	h := server.hub
+
+	conn := h.newConnection()
+	if cap(conn.send) != 4 {
+		t.Fatalf("SendBufferSize 未生效，得到 %d", cap(conn.send))
+	}
+	h.registerConnection(conn)
+	waitUntil(t, time.Second, func() bool {
+		return h.GetActiveConnectionCount() == 1
+	}, "连接未注册")
+
+	for i := 0; i < 50; i++ {
+		h.broadcast <- SSEMessage{Event: "burst", Data: []byte("payload")}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if h.GetActiveConnectionCount() != 1 {
+		t.Error("丢弃最旧策略下慢消费者不应被断开")
+	}
+	if len(conn.send) != 4 {
+		t.Errorf("发送缓冲应保持满载，得到 %d", len(conn.send))
+	}
+}
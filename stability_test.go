@@ -110,13 +110,13 @@ func TestStability_ConnectionChurnAndBroadcast(t *testing.T) {
 
 func TestStability_SlowConsumerIsolation(t *testing.T) {
 	h := newHub()
-	h.Start(false)
+	h.Start()
 	defer h.Stop()
 
 	// 构造一个极小缓冲慢消费者，确保快速触发背压。
 	conn := h.newConnection()
-	conn.send = make(chan []byte, 1)
-	h.register <- conn
+	conn.send = make(chan *frame, 1)
+	h.registerConnection(conn)
 
 	waitUntil(t, 2*time.Second, func() bool {
 		return h.GetActiveConnectionCount() == 1
@@ -0,0 +1,38 @@
+package sseserver
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// MessageStore 保存已广播的消息，供携带 Last-Event-ID 重连的客户端补发。
+// 内存实现见 NewMemoryStore，进程重启后仍需补发时使用 NewFileStore。
+type MessageStore interface {
+	// Append 保存一条已广播的消息，消息按调用顺序保存
+	Append(msg SSEMessage) error
+	// ReadSince 返回订阅 patterns（可含通配符，空字符串表示全部）的连接在 id 之后错过的消息，
+	// 按保存顺序排列，没有 namespace 的消息总是返回。id 不在存储中（已被清理或来自其它实例）时
+	// 从最早保留的消息读起。until 非空时读到该 ID 的消息（含）为止，不再读取之后的消息；
+	// until 不在存储中时返回空。
+	ReadSince(patterns []string, id, until string) ([]SSEMessage, error)
+	// Trim 按保留策略删除过期消息
+	Trim() error
+}
+
+// seedID 从存储中最后一条消息的 ID 恢复自增计数，避免重启后分配重复的 ID。
+// 支持纯数字 ID 与多节点部署时的 "<nodeID>-<seq>" 格式。
+func (h *hub) seedID() {
+	msgs, err := h.store.ReadSince([]string{""}, "", "")
+	if err != nil || len(msgs) == 0 {
+		return
+	}
+	id := msgs[len(msgs)-1].ID
+	h.storeLastID = id
+	if i := strings.LastIndexByte(id, '-'); i >= 0 {
+		id = id[i+1:]
+	}
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil && n > atomic.LoadUint64(&h.nextID) {
+		atomic.StoreUint64(&h.nextID, n)
+	}
+}
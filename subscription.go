@@ -0,0 +1,201 @@
+package sseserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// subscribeNamespaces 解析订阅请求的 namespace：路径部分与 ?namespace= 查询参数可同时使用。
+// 两者都没有时订阅全部消息。
+func subscribeNamespaces(r *http.Request) ([]string, error) {
+	var namespaces []string
+	if ns := normalizeNamespace(r.URL.Path); ns != "" {
+		namespaces = append(namespaces, ns)
+	}
+	for _, ns := range r.URL.Query()["namespace"] {
+		namespaces = append(namespaces, normalizeNamespace(ns))
+	}
+	if len(namespaces) == 0 {
+		namespaces = append(namespaces, "")
+	}
+	for _, ns := range namespaces {
+		if err := validatePattern(ns); err != nil {
+			return nil, err
+		}
+	}
+	return dedupeNamespaces(namespaces), nil
+}
+
+func dedupeNamespaces(namespaces []string) []string {
+	out := namespaces[:0]
+	for _, ns := range namespaces {
+		if !containsNamespace(out, ns) {
+			out = append(out, ns)
+		}
+	}
+	return out
+}
+
+func containsNamespace(namespaces []string, ns string) bool {
+	for _, n := range namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// connectedFrame 是连接建立后发送的第一条事件，告知客户端自己的连接 ID
+func connectedFrame(id string) []byte {
+	data, _ := json.Marshal(map[string]string{"id": id})
+	return SSEMessage{Event: "connected", Data: data}.Bytes()
+}
+
+type subscriptionRequest struct {
+	Subscribe   []string `json:"subscribe"`
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+// addSubscriptionEndpoint 注册 POST /subscriptions/{connID}，请求体形如
+// {"subscribe": ["/a"], "unsubscribe": ["/b"]}，返回连接当前订阅的 namespace 列表。
+// 配置了 Authenticate 时，请求的身份必须与连接的身份一致，新增的 namespace 同样经过 Authorize。
+func (s *Server) addSubscriptionEndpoint() {
+	s.mux.Handle("/subscriptions/", s.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/subscriptions/")
+		var req subscriptionRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		for i, ns := range req.Subscribe {
+			req.Subscribe[i] = normalizeNamespace(ns)
+			if err := validatePattern(req.Subscribe[i]); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		for i, ns := range req.Unsubscribe {
+			req.Unsubscribe[i] = normalizeNamespace(ns)
+		}
+
+		conn := s.hub.connectionByID(id)
+		if conn == nil {
+			http.Error(w, ErrConnectionNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if s.Options.Authenticate != nil {
+			principal, err := s.Options.Authenticate(r)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			// 不暴露连接是否存在，身份不一致时与连接不存在同样处理
+			if principal.ID != conn.principal.ID {
+				http.Error(w, ErrConnectionNotFound.Error(), http.StatusNotFound)
+				return
+			}
+		}
+		if !s.authorize(conn.principal, req.Subscribe) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		namespaces, err := s.hub.updateSubscriptions(conn, req.Subscribe, req.Unsubscribe)
+		if err == ErrNamespaceLimit {
+			s.logger.Debug("subscription rejected", "conn", conn.id, "namespace", req.Subscribe, "reason", rejectReasonNames[rejectNamespace])
+			(&rejection{reason: rejectNamespace, status: http.StatusServiceUnavailable, retryAfter: conn.admission.retryAfter}).write(w)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string][]string{"namespaces": namespaces})
+	})))
+}
+
+// Subscribe 为连接增加订阅的 namespace
+func (s *Server) Subscribe(connID string, namespaces ...string) error {
+	return s.updateSubscriptions(connID, namespaces, nil)
+}
+
+// Unsubscribe 取消连接对 namespace 的订阅
+func (s *Server) Unsubscribe(connID string, namespaces ...string) error {
+	return s.updateSubscriptions(connID, nil, namespaces)
+}
+
+func (s *Server) updateSubscriptions(connID string, add, remove []string) error {
+	conn := s.hub.connectionByID(connID)
+	if conn == nil {
+		return ErrConnectionNotFound
+	}
+	subscribe := make([]string, 0, len(add))
+	for _, ns := range add {
+		ns = normalizeNamespace(ns)
+		if err := validatePattern(ns); err != nil {
+			return err
+		}
+		subscribe = append(subscribe, ns)
+	}
+	unsubscribe := make([]string, 0, len(remove))
+	for _, ns := range remove {
+		unsubscribe = append(unsubscribe, normalizeNamespace(ns))
+	}
+	_, err := s.hub.updateSubscriptions(conn, subscribe, unsubscribe)
+	return err
+}
+
+func (h *hub) connectionByID(id string) *connection {
+	sh := h.shardFor(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	return sh.byID[id]
+}
+
+// updateSubscriptions 动态增删连接订阅的 namespace，返回更新后的列表。
+// 实际新增的 namespace 先经过准入检查，超过单 namespace 上限时不做任何修改并返回 ErrNamespaceLimit。
+// conn.namespaces 整体替换而不原地修改，已交给其它地方的切片不受影响。
+func (h *hub) updateSubscriptions(conn *connection, add, remove []string) ([]string, error) {
+	sh := h.shardFor(conn.id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.byID[conn.id] != conn {
+		return nil, ErrConnectionNotFound
+	}
+	var added, removed []string
+	next := make([]string, 0, len(conn.namespaces)+len(add))
+	for _, ns := range conn.namespaces {
+		if containsNamespace(remove, ns) {
+			removed = append(removed, ns)
+		} else {
+			next = append(next, ns)
+		}
+	}
+	for _, ns := range add {
+		if !containsNamespace(next, ns) {
+			added = append(added, ns)
+			next = append(next, ns)
+		}
+	}
+	if conn.admission != nil {
+		if rejected := conn.admission.update(added, removed); rejected != nil {
+			return nil, ErrNamespaceLimit
+		}
+	}
+	for _, ns := range removed {
+		sh.subscriptions.remove(ns, conn)
+	}
+	h.presenceLeave(conn, removed)
+	for _, ns := range added {
+		sh.subscriptions.add(ns, conn)
+	}
+	h.presenceJoin(conn, added)
+	conn.namespaces = next
+	sh.updateMultiSubscription(conn)
+	return append([]string(nil), next...), nil
+}
@@ -0,0 +1,121 @@
+package sseserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHubMultiNamespaceDedup(t *testing.T) {
+	h := newHub()
+	h.Start()
+	defer h.Stop()
+	conn := h.newConnection()
+	conn.namespaces = []string{"/device/42", "/device/#", "/other"}
+	h.registerConnection(conn)
+
+	result := publishSync(t, h, SSEMessage{Data: []byte("x"), Namespace: "/device/42"})
+	if result.Accepted != 1 {
+		t.Errorf("多个订阅同时匹配时应只投递一次，得到 %+v", result)
+	}
+	if got := drainFrames(conn); len(got) != 1 {
+		t.Errorf("连接应只收到一帧，得到 %q", got)
+	}
+
+	h.unregisterConnection(conn)
+	if len(h.shardFor(conn.id).multiSubscribed) != 0 {
+		t.Errorf("注销后 multiSubscribed 未清理")
+	}
+}
+
+func TestSubscriptionAPI(t *testing.T) {
+	server := NewServer(ServerOptions{
+		EnableSubscriptionAPI: true,
+		Authenticate: func(r *http.Request) (Principal, error) {
+			if user := r.Header.Get("X-User"); user != "" {
+				return Principal{ID: user}, nil
+			}
+			return Principal{}, errors.New("missing user")
+		},
+		Authorize: func(p Principal, namespace string) error {
+			if namespace == "/admin" {
+				return errors.New("forbidden")
+			}
+			return nil
+		},
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(server.ServeHTTP))
+	defer ts.Close()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/subscribe/a?namespace=b", nil)
+	req.Header.Set("X-User", "alice")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	readFrame := func() string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("读取响应失败: %v", err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	connected := readFrame()
+	id := resp.Header.Get("X-SSE-Connection-ID")
+	if expected := "event:connected\ndata:{\"id\":\"" + id + "\"}\n"; connected != expected {
+		t.Fatalf("connected 事件错误。得到: %q, 想要: %q", connected, expected)
+	}
+
+	post := func(user, body string) *http.Response {
+		req, _ := http.NewRequest("POST", ts.URL+"/subscriptions/"+id, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if r := post("bob", `{"subscribe":["/c"]}`); r.StatusCode != http.StatusNotFound {
+		t.Errorf("其它用户不应能修改连接订阅，得到状态码 %d", r.StatusCode)
+	}
+	if r := post("alice", `{"subscribe":["/admin"]}`); r.StatusCode != http.StatusForbidden {
+		t.Errorf("未授权的 namespace 应返回 403，得到状态码 %d", r.StatusCode)
+	}
+
+	r := post("alice", `{"subscribe":["c"],"unsubscribe":["/a"]}`)
+	var body struct {
+		Namespaces []string `json:"namespaces"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK || strings.Join(body.Namespaces, ",") != "/b,/c" {
+		t.Fatalf("更新订阅失败，状态码 %d，得到 %v", r.StatusCode, body.Namespaces)
+	}
+
+	server.Broadcast <- SSEMessage{Data: []byte("skip"), Namespace: "/a"}
+	server.Broadcast <- SSEMessage{Data: []byte("ok"), Namespace: "/c"}
+	if got := readFrame(); got != "namespace:/c\ndata:ok\n" {
+		t.Errorf("动态订阅后收到的消息错误，得到 %q", got)
+	}
+}
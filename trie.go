@@ -0,0 +1,168 @@
+package sseserver
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// 订阅 namespace 支持通配符：
+//
+//	/device/*/telemetry  * 匹配一级路径，如 /device/42/telemetry
+//	/device/#            # 只能出现在末尾，匹配零或多级路径，如 /device、/device/42/telemetry
+//
+// 订阅根路径（空 namespace）等价于 /#。
+const (
+	wildcardOne  = "*"
+	wildcardRest = "#"
+)
+
+var errInvalidPattern = errors.New("'#' must be the last segment of a namespace")
+
+// validatePattern 检查订阅 namespace 中 # 的位置
+func validatePattern(pattern string) error {
+	segs := splitNamespace(pattern)
+	for i, seg := range segs {
+		if seg == wildcardRest && i != len(segs)-1 {
+			return errInvalidPattern
+		}
+	}
+	return nil
+}
+
+// splitNamespace 将规范化后的 namespace 拆分为路径段，空 namespace 返回 nil
+func splitNamespace(ns string) []string {
+	if ns == "" {
+		return nil
+	}
+	return strings.Split(ns[1:], "/")
+}
+
+// patternSegments 返回订阅 namespace 的路径段，根订阅视为 #
+func patternSegments(pattern string) []string {
+	if pattern == "" {
+		return []string{wildcardRest}
+	}
+	return splitNamespace(pattern)
+}
+
+// namespaceMatches 判断消息 namespace 是否匹配订阅 pattern，两者都需已规范化
+func namespaceMatches(pattern, ns string) bool {
+	return matchSegments(patternSegments(pattern), splitNamespace(ns))
+}
+
+func matchSegments(pattern, segs []string) bool {
+	for i, p := range pattern {
+		if p == wildcardRest {
+			return true
+		}
+		if i >= len(segs) || (p != wildcardOne && p != segs[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(segs)
+}
+
+// nsTrie 按路径段组织订阅，广播时沿消息 namespace 的路径段查找订阅者，
+// 代价与路径深度相关，而与连接总数无关
+type nsTrie struct {
+	root *nsNode
+}
+
+type nsNode struct {
+	children map[string]*nsNode
+	subs     map[*connection]bool
+}
+
+func newNSTrie() *nsTrie {
+	return &nsTrie{root: &nsNode{}}
+}
+
+func (t *nsTrie) add(pattern string, conn *connection) {
+	node := t.root
+	for _, seg := range patternSegments(pattern) {
+		if node.children == nil {
+			node.children = make(map[string]*nsNode)
+		}
+		child := node.children[seg]
+		if child == nil {
+			child = &nsNode{}
+			node.children[seg] = child
+		}
+		node = child
+	}
+	if node.subs == nil {
+		node.subs = make(map[*connection]bool)
+	}
+	node.subs[conn] = true
+}
+
+func (t *nsTrie) remove(pattern string, conn *connection) {
+	t.root.remove(patternSegments(pattern), conn)
+}
+
+// remove 删除订阅并回收空节点，返回该节点是否已为空
+func (n *nsNode) remove(segs []string, conn *connection) bool {
+	if len(segs) == 0 {
+		delete(n.subs, conn)
+	} else if child := n.children[segs[0]]; child != nil {
+		if child.remove(segs[1:], conn) {
+			delete(n.children, segs[0])
+		}
+	}
+	return len(n.subs) == 0 && len(n.children) == 0
+}
+
+// appendMatches 将订阅 pattern 匹配 ns 的连接追加到 conns
+func (t *nsTrie) appendMatches(conns []*connection, ns string) []*connection {
+	return t.root.appendMatches(conns, splitNamespace(ns))
+}
+
+func (n *nsNode) appendMatches(conns []*connection, segs []string) []*connection {
+	if rest := n.children[wildcardRest]; rest != nil {
+		for conn := range rest.subs {
+			conns = append(conns, conn)
+		}
+	}
+	if len(segs) == 0 {
+		for conn := range n.subs {
+			conns = append(conns, conn)
+		}
+		return conns
+	}
+	if child := n.children[segs[0]]; child != nil {
+		conns = child.appendMatches(conns, segs[1:])
+	}
+	if child := n.children[wildcardOne]; child != nil {
+		conns = child.appendMatches(conns, segs[1:])
+	}
+	return conns
+}
+
+type namespaceCount struct {
+	namespace string
+	count     int
+}
+
+// counts 返回每个订阅 pattern 的连接数，按 pattern 排序
+func (t *nsTrie) counts() []namespaceCount {
+	var counts []namespaceCount
+	var walk func(n *nsNode, path []string)
+	walk = func(n *nsNode, path []string) {
+		if len(n.subs) > 0 {
+			ns := "/" + strings.Join(path, "/")
+			if ns == "/"+wildcardRest {
+				ns = ""
+			}
+			counts = append(counts, namespaceCount{namespace: ns, count: len(n.subs)})
+		}
+		for seg, child := range n.children {
+			walk(child, append(path[:len(path):len(path)], seg))
+		}
+	}
+	walk(t.root, nil)
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].namespace < counts[j].namespace
+	})
+	return counts
+}
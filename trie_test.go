@@ -0,0 +1,84 @@
+package sseserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+func TestNamespaceMatches(t *testing.T) {
+	cases := []struct {
+		pattern, ns string
+		want        bool
+	}{
+		{"/device/42/telemetry", "/device/42/telemetry", true},
+		{"/device/*/telemetry", "/device/42/telemetry", true},
+		{"/device/*/telemetry", "/device/42/status", false},
+		{"/device/*", "/device/42/telemetry", false},
+		{"/device/#", "/device", true},
+		{"/device/#", "/device/42/telemetry", true},
+		{"/device/#", "/devices/42", false},
+		{"", "/anything/at/all", true},
+	}
+	for _, tc := range cases {
+		if got := namespaceMatches(tc.pattern, tc.ns); got != tc.want {
+			t.Errorf("namespaceMatches(%q, %q) = %v，想要 %v", tc.pattern, tc.ns, got, tc.want)
+		}
+	}
+}
+
+func TestNSTrie(t *testing.T) {
+	trie := newNSTrie()
+	conns := map[string]*connection{}
+	for _, pattern := range []string{"", "/device/42/telemetry", "/device/*/telemetry", "/device/#", "/other"} {
+		conn := &connection{namespaces: []string{pattern}}
+		conns[pattern] = conn
+		trie.add(pattern, conn)
+	}
+
+	matched := func(ns string) []string {
+		var patterns []string
+		for _, conn := range trie.appendMatches(nil, ns) {
+			patterns = append(patterns, conn.namespaces[0])
+		}
+		sort.Strings(patterns)
+		return patterns
+	}
+
+	got := matched("/device/42/telemetry")
+	want := []string{"", "/device/#", "/device/*/telemetry", "/device/42/telemetry"}
+	if len(got) != len(want) {
+		t.Fatalf("匹配结果错误，得到 %q，想要 %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("匹配结果错误，得到 %q，想要 %q", got, want)
+		}
+	}
+
+	trie.remove("/device/#", conns["/device/#"])
+	trie.remove("/other", conns["/other"])
+	if got := matched("/device/7"); len(got) != 1 || got[0] != "" {
+		t.Errorf("移除后匹配结果错误，得到 %q", got)
+	}
+	if _, ok := trie.root.children["other"]; ok {
+		t.Error("空节点未被回收")
+	}
+
+	counts := trie.counts()
+	if len(counts) != 3 || counts[0].namespace != "" {
+		t.Errorf("counts 结果错误: %+v", counts)
+	}
+}
+
+func TestSubscribeInvalidPattern(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/subscribe/device/%23/telemetry", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("# 不在末尾时应返回 400，得到 %d", rr.Code)
+	}
+}